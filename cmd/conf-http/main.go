@@ -1,9 +1,12 @@
 package main
 
 import (
+	"Tool-Library/components/BuildReport"
+	"Tool-Library/components/VersionDiff"
 	conf_tool "Tool-Library/components/conf-tool"
 	"Tool-Library/components/filemode"
 	"Tool-Library/components/md5"
+	"Tool-Library/shared/config"
 	"flag"
 	"fmt"
 	jsoniter "github.com/json-iterator/go"
@@ -29,6 +32,8 @@ var dbBasePath = "./gen/conf-http/"
 
 var dbPath = "./gen/conf-http/" + strconv.Itoa(packVersion) + "/"
 
+var signKey = flag.String("signKey", "", "version.txt的ed25519签名私钥路径，为空不签名")
+
 func main() {
 	flag.Parse()
 
@@ -42,6 +47,8 @@ func main() {
 
 	http.HandleFunc("/client/cs/", clientCsHandleFunc)
 
+	http.HandleFunc("/client/diff", clientDiffHandleFunc)
+
 	fmt.Println("listen port:", *port)
 	http.ListenAndServe(fmt.Sprintf(":%d", *port), nil)
 }
@@ -63,6 +70,20 @@ func writeErrMsg(w http.ResponseWriter, s string) {
 	//return []byte(fmt.Sprintf(`{"code":400,"message":"%s"}`, s))
 }
 
+// writeErrReport 转表失败时把转表报告和标记了错误的表格一起返回给上传的人
+func writeErrReport(w http.ResponseWriter, s string, errJson *ConfigErrJson) {
+	if errJson == nil {
+		writeErrMsg(w, s)
+		return
+	}
+
+	jsoniter.NewEncoder(w).Encode(&response{
+		Code:    400,
+		Message: s,
+		Data:    errJson,
+	})
+}
+
 func writeConfigJson(w http.ResponseWriter, data *ConfigJson, cmdOutPut string) {
 	jsoniter.NewEncoder(w).Encode(&response{
 		Code:    200,
@@ -125,11 +146,11 @@ func (m *manager) handleFunc(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = Generate(m.bucket, confJson, data)
+	errJson, err := Generate(m.bucket, confJson, data)
 
 	if err != nil {
 		fmt.Println("重新生成版本文件报错,generate:", err)
-		writeErrMsg(w, fmt.Sprintf("生成错误，err: %v", err))
+		writeErrReport(w, fmt.Sprintf("生成错误，err: %v", err), errJson)
 		return
 	}
 
@@ -137,8 +158,8 @@ func (m *manager) handleFunc(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-// data是个zip压缩文件
-func Generate(bucket *blob.Bucket, configJson *ConfigJson, packBytes []byte) error {
+// data是个zip压缩文件，转表失败时返回转表报告和标记了错误的表格
+func Generate(bucket *blob.Bucket, configJson *ConfigJson, packBytes []byte) (*ConfigErrJson, error) {
 	genMux := &sync.Mutex{}
 	genMux.Lock()
 	defer genMux.Unlock()
@@ -146,7 +167,7 @@ func Generate(bucket *blob.Bucket, configJson *ConfigJson, packBytes []byte) err
 	tempDir, err := os.MkdirTemp("tmp/", "excel-")
 
 	if err != nil {
-		return errors.Errorf("os.MkdirTemp fail, err: %v", err)
+		return nil, errors.Errorf("os.MkdirTemp fail, err: %v", err)
 	}
 
 	defer os.RemoveAll(tempDir)
@@ -154,12 +175,12 @@ func Generate(bucket *blob.Bucket, configJson *ConfigJson, packBytes []byte) err
 	err = filemode.MkdirAll(dbPath, 777)
 
 	if err != nil {
-		return errors.Errorf("filemode.MkdirAll(%s) fail, err: %v", dbPath, err)
+		return nil, errors.Errorf("filemode.MkdirAll(%s) fail, err: %v", dbPath, err)
 	}
 
 	fileMap, err := conf_tool.UnpackData(packBytes)
 	if err != nil {
-		return errors.Wrapf(err, "unpackData")
+		return nil, errors.Wrapf(err, "unpackData")
 	}
 
 	for filename, fileBytes := range fileMap {
@@ -173,27 +194,35 @@ func Generate(bucket *blob.Bucket, configJson *ConfigJson, packBytes []byte) err
 			continue
 		}
 
-		if !strings.HasSuffix(basename, ".xlsx") {
+		if !config.IsTableFile(basename) {
 			continue
 		}
 
 		// 将excel写入到本地磁盘
 		excelPath := fmt.Sprintf("%s/%s", tempDir, filename)
 		if err = conf_tool.WriteFile(excelPath, fileBytes); err != nil {
-			return errors.Wrapf(err, "writeFile")
+			return nil, errors.Wrapf(err, "writeFile")
 		}
 	}
 
-	errorRun := conf_tool.RunCommand("./bin/exceltodb.exe", "--dbPath="+dbPath, "--conf="+tempDir, "--joinPath="+strconv.Itoa(packVersion)+"/")
+	reportPath := tempDir + "/report.json"
+	annotatePath := tempDir + "/annotated/"
+
+	args := []string{"--dbPath=" + dbPath, "--conf=" + tempDir, "--joinPath=" + strconv.Itoa(packVersion) + "/", "--report=" + reportPath, "--annotate=" + annotatePath, "--packVersion=" + strconv.Itoa(packVersion)}
+	if *signKey != "" {
+		args = append(args, "--signKey="+*signKey)
+	}
+
+	errorRun := conf_tool.RunCommand("./bin/exceltodb.exe", args...)
 
 	if errorRun != nil {
-		return errors.Errorf("EXE 执行失败:%v", errorRun)
+		return loadErrJson(reportPath, annotatePath), errors.Errorf("EXE 执行失败:%v", errorRun)
 	}
 
 	_, errVersionStat := os.Stat(dbPath + "version.txt")
 
 	if os.IsNotExist(errVersionStat) {
-		return errors.Errorf("不存在版本文件")
+		return nil, errors.Errorf("不存在版本文件")
 	}
 
 	dataMd5 := md5.String(packBytes)
@@ -205,7 +234,7 @@ func Generate(bucket *blob.Bucket, configJson *ConfigJson, packBytes []byte) err
 
 	fmt.Println("生成成功:", configJson.VersionPath)
 
-	return nil
+	return nil, nil
 }
 
 type ConfigJson struct {
@@ -218,12 +247,46 @@ type ConfigJson struct {
 	VersionMd5 string `json:"version_md5"`
 }
 
+type ConfigErrJson struct {
+	Report *BuildReport.Report `json:"report"`
+
+	// 标记了错误单元格的表格副本，zip压缩
+	AnnotatedZip []byte `json:"annotated_zip,omitempty"`
+}
+
+// loadErrJson 读取exceltodb生成的转表报告和标记表格，读取失败返回nil，只返回错误信息
+func loadErrJson(reportPath string, annotatePath string) *ConfigErrJson {
+	report, err := BuildReport.Load(reportPath)
+	if err != nil {
+		fmt.Println("读取转表报告失败:", err)
+		return nil
+	}
+
+	errJson := &ConfigErrJson{
+		Report: &BuildReport.Report{Entries: report.Sorted()},
+	}
+
+	if _, errStat := os.Stat(annotatePath); errStat == nil {
+		annotatedZip, errZip := conf_tool.ZipFiles(annotatePath)
+		if errZip != nil {
+			fmt.Println("压缩标记表格失败:", errZip)
+		} else {
+			errJson.AnnotatedZip = annotatedZip
+		}
+	}
+
+	return errJson
+}
+
 type ConfigCsJson struct {
 	FileMd5 string `json:"file_md5"`
 
 	FileSize int64 `json:"file_size"`
 
 	CsBody []byte `json:"cs_body"`
+
+	// 转表失败时的转表报告
+	Report *BuildReport.Report `json:"report,omitempty"`
 }
 
 func getVersionName(fileMd5 string, fileSizeInt64 int64) string {
@@ -329,7 +392,7 @@ func GenerateCs(configJson *ConfigCsJson, packBytes []byte) error {
 			continue
 		}
 
-		if !strings.HasSuffix(basename, ".xlsx") {
+		if !config.IsTableFile(basename) {
 			continue
 		}
 
@@ -340,9 +403,14 @@ func GenerateCs(configJson *ConfigCsJson, packBytes []byte) error {
 		}
 	}
 
-	errorRun := conf_tool.RunCommand("./bin/csGen.exe", "--conf="+path.Dir(conf_tool.TransPath(tempConfDir)), "--csPath="+path.Dir(conf_tool.TransPath(tempCsDir)))
+	reportPath := tempConfDir + "/report.json"
+
+	errorRun := conf_tool.RunCommand("./bin/csGen.exe", "--conf="+path.Dir(conf_tool.TransPath(tempConfDir)), "--csPath="+path.Dir(conf_tool.TransPath(tempCsDir)), "--report="+reportPath)
 
 	if errorRun != nil {
+		if report, errLoad := BuildReport.Load(reportPath); errLoad == nil {
+			configJson.Report = &BuildReport.Report{Entries: report.Sorted()}
+		}
 		return errors.Errorf("EXE 执行失败:%v", errorRun)
 	}
 
@@ -358,3 +426,74 @@ func GenerateCs(configJson *ConfigCsJson, packBytes []byte) error {
 
 	return nil
 }
+
+// clientDiffHandleFunc 比较客户端已有的版本和新版本，返回需要下载的数据库和总大小
+// from、to 是生成时返回的 version_path，例如 4/version_md5_size.txt，也可以只传文件名
+func clientDiffHandleFunc(w http.ResponseWriter, r *http.Request) {
+	fromPath, errFrom := versionFilePath(r.URL.Query().Get("from"))
+	if errFrom != nil {
+		writeErrMsg(w, fmt.Sprintf("from: %v", errFrom))
+		return
+	}
+
+	toPath, errTo := versionFilePath(r.URL.Query().Get("to"))
+	if errTo != nil {
+		writeErrMsg(w, fmt.Sprintf("to: %v", errTo))
+		return
+	}
+
+	from, err := VersionDiff.Load(fromPath)
+	if err != nil {
+		writeErrMsg(w, err.Error())
+		return
+	}
+
+	to, err := VersionDiff.Load(toPath)
+	if err != nil {
+		writeErrMsg(w, err.Error())
+		return
+	}
+
+	//数据库和各自的version.txt在同一个打包版本目录，新版本的数据库客户端要下载，必须存在
+	missing, err := VersionDiff.FillSizes(to, path.Dir(toPath))
+	if err != nil {
+		writeErrMsg(w, err.Error())
+		return
+	}
+
+	if len(missing) > 0 {
+		writeErrMsg(w, fmt.Sprintf("数据库文件不存在 %v", missing))
+		return
+	}
+
+	//旧版本的数据库只用来显示大小，已经清理时大小为0
+	if _, err = VersionDiff.FillSizes(from, path.Dir(fromPath)); err != nil {
+		writeErrMsg(w, err.Error())
+		return
+	}
+
+	jsoniter.NewEncoder(w).Encode(&response{
+		Code: 200,
+		Data: VersionDiff.Compare(from, to),
+	})
+}
+
+// versionFilePath 检查客户端传的版本文件，只能是dbBasePath下的version_*.txt
+func versionFilePath(version string) (string, error) {
+	if version == "" {
+		return "", errors.Errorf("version is empty")
+	}
+
+	version = strings.TrimPrefix(path.Clean("/"+version), "/")
+
+	name := path.Base(version)
+	if !strings.HasPrefix(name, "version_") || path.Ext(name) != ".txt" {
+		return "", errors.Errorf("invalid version %v", version)
+	}
+
+	if path.Dir(version) == "." {
+		version = strconv.Itoa(packVersion) + "/" + version
+	}
+
+	return dbBasePath + version, nil
+}
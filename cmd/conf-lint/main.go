@@ -0,0 +1,60 @@
+package main
+
+import (
+	"Tool-Library/components/BuildReport"
+	"Tool-Library/components/ConfLint"
+	"Tool-Library/components/ProtoIDGen"
+	"Tool-Library/shared/config"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// 检查配置表结构，有错误时退出码为1，可以作为git pre-commit hook
+// conf-lint --conf=conf            检查整个目录
+// conf-lint conf/Hero.xlsx ...     只检查指定的表格
+func main() {
+	confPath := "conf"
+	flag.StringVar(&confPath, "conf", confPath, "指定配置表格路径，命令行后面有表格文件时只检查这些文件")
+
+	reportPath := ""
+	flag.StringVar(&reportPath, "report", reportPath, "检查报告JSON路径，为空不写入")
+
+	warnAsError := false
+	flag.BoolVar(&warnAsError, "warnAsError", warnAsError, "有警告时也返回失败")
+
+	flag.Func("protoCase", "proto字段名命名风格 keep snake camel pascal，和转表时一致", func(s string) error {
+		return config.Naming.SetCase(config.OutputProto, s)
+	})
+
+	flag.BoolVar(&ProtoIDGen.PackagePerWorkbook, "packagePerWorkbook", ProtoIDGen.PackagePerWorkbook, "每个表格生成单独的proto package，和转表时一致")
+	flag.BoolVar(&ProtoIDGen.NestedMessage, "nestedMessage", ProtoIDGen.NestedMessage, "标题中用点分隔的列生成嵌套消息，和转表时一致")
+
+	flag.Parse()
+
+	timeCost := time.Now()
+
+	report := BuildReport.New()
+
+	if files := flag.Args(); len(files) > 0 {
+		ConfLint.LintFiles(confPath, files, report)
+	} else if errLint := ConfLint.LintDir(confPath, report); errLint != nil {
+		os.Stderr.WriteString(fmt.Sprintf("检查配置表失败 Err:%v\n", errLint))
+		os.Exit(1)
+	}
+
+	report.PrintSummary()
+
+	if reportPath != "" {
+		if errReport := report.WriteJSON(reportPath); errReport != nil {
+			fmt.Println("写入检查报告失败 Err:", errReport)
+		}
+	}
+
+	fmt.Println("检查耗时：", time.Since(timeCost))
+
+	if report.ErrorCount() > 0 || (warnAsError && report.WarningCount() > 0) {
+		os.Exit(1)
+	}
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"Tool-Library/components/BuildCache"
+	"Tool-Library/components/BuildReport"
+	"Tool-Library/components/ColumnType"
+	"Tool-Library/components/MatchConstGen"
 	"Tool-Library/components/ProtoIDGen"
-	conf_tool "Tool-Library/components/conf-tool"
+	"Tool-Library/components/WorkerPool"
 	excel_to_proto "Tool-Library/components/excel-to-proto"
 	"Tool-Library/components/filemode"
-	"encoding/json"
+	"Tool-Library/shared/config"
 	"flag"
 	"fmt"
 	"os"
@@ -22,8 +26,47 @@ func main() {
 	csPath := genPath + "cs/"
 	flag.StringVar(&csPath, "csPath", csPath, "指定CS生成路径")
 
+	csConstPath := ""
+	flag.StringVar(&csConstPath, "csConstPath", csConstPath, "match_int/match_text页签生成的C#常量路径，默认和csPath相同")
+
+	flag.BoolVar(&MatchConstGen.CsEnum, "csEnum", MatchConstGen.CsEnum, "match常量生成C# enum，否则生成 static class")
+
+	goConstPath := ""
+	flag.StringVar(&goConstPath, "goConstPath", goConstPath, "match_int/match_text页签生成的Go常量路径，为空不生成")
+
+	goConstPackage := "conf"
+	flag.StringVar(&goConstPackage, "goConstPackage", goConstPackage, "Go常量的package名")
+
+	reportPath := ""
+	flag.StringVar(&reportPath, "report", reportPath, "转表报告JSON路径（所有错误和警告），为空不写入")
+
+	flag.Func("protoCase", "proto字段名命名风格 keep snake camel pascal，默认keep", func(s string) error {
+		return config.Naming.SetCase(config.OutputProto, s)
+	})
+
+	flag.StringVar(&config.Naming.KeywordSuffix, "keywordSuffix", config.Naming.KeywordSuffix, "字段名和关键字重名时加的后缀")
+
+	flag.Func("csCase", "match常量C#命名风格 keep snake camel pascal，默认keep", func(s string) error {
+		return config.Naming.SetCase(config.OutputCSharp, s)
+	})
+
+	flag.Func("goCase", "match常量Go命名风格 keep snake camel pascal，默认keep", func(s string) error {
+		return config.Naming.SetCase(config.OutputGo, s)
+	})
+
+	flag.BoolVar(&ProtoIDGen.PackagePerWorkbook, "packagePerWorkbook", ProtoIDGen.PackagePerWorkbook, "每个表格生成单独的proto package（conf.表格名），C# namespace和Go package一致")
+	flag.BoolVar(&ProtoIDGen.NestedMessage, "nestedMessage", ProtoIDGen.NestedMessage, "标题中用点分隔的列生成嵌套消息（pos.x、drops.0.id），不开启时生成普通字段（pos_x）")
+
+	flag.IntVar(&WorkerPool.Jobs, "jobs", WorkerPool.Jobs, "同时处理的表格数量，0使用CPU核数")
+
+	flag.BoolVar(&ColumnType.TimeAsSeconds, "timeAsSeconds", ColumnType.TimeAsSeconds, "时间类型生成int64秒，否则生成google.protobuf.Timestamp/Duration")
+
 	flag.Parse()
 
+	if csConstPath == "" {
+		csConstPath = csPath
+	}
+
 	ProtoPath := genPath + "proto/"
 
 	idGenPath := ProtoPath + "proto_id.yaml"
@@ -44,6 +87,14 @@ func main() {
 		return
 	}
 
+	//ProtoID记录和ProtoVersion和excel-to-db共用，同时只有一个进程读写
+	protoLock, errLock := BuildCache.LockDir(ProtoPath)
+	if errLock != nil {
+		fmt.Println("锁定proto目录失败 Err:", errLock)
+		return
+	}
+	defer protoLock.Unlock()
+
 	//加载旧ProtoID表进来
 	protoIdGen, errLoadGen := ProtoIDGen.LoadGen(idGenPath)
 
@@ -52,58 +103,56 @@ func main() {
 		return
 	}
 
-	protoVersionPath := ProtoPath + excel_to_proto.ProtoVersionName
-
-	protoVersionJson, errProtoVersion := os.ReadFile(protoVersionPath)
-	if errProtoVersion != nil && os.IsNotExist(errProtoVersion) {
-		//不存在直接创建
-		fmt.Println("对应路径下不存在ProtoVersion，直接创建,路径：", protoVersionPath)
-		fp, errCreate := os.Create(idGenPath) // 如果文件已存在，会将文件清空。
-		if errCreate != nil {
-			fmt.Printf("创建在对应路径下不存在ProtoVersion失败，Err: %v", errCreate)
-			return
-		}
-
-		protoVersionJson, errProtoVersion = os.ReadFile(idGenPath)
-		if errProtoVersion != nil {
-			fmt.Printf("创建在ProtoID记录后，重新读取失败: %v", errProtoVersion)
-			return
-		}
-		// defer延迟调用
-		defer fp.Close() //关闭文件，释放资源。
+	protoVersionData, errLoad := excel_to_proto.LoadProtoVersion(ProtoPath)
+	if errLoad != nil {
+		fmt.Println("ProtoVersion读取失败，重新生成所有proto：", errLoad)
+		protoVersionData = map[string]excel_to_proto.ProtoVersion{}
 	}
 
-	protoVersionData := map[string]excel_to_proto.ProtoVersion{}
-
-	json.Unmarshal(protoVersionJson, &protoVersionData)
+	for _, invalid := range excel_to_proto.ValidateProtoVersion(ProtoPath, protoVersionData) {
+		fmt.Println("ProtoVersion记录无效：", invalid)
+	}
 
 	fmt.Println("--------加载ProtoMd5Json结束--------")
 
 	fmt.Println("--------开始生成Proto--------")
 
 	//加载表去生成对应proto
+	report := BuildReport.New()
+	defer func() {
+		report.PrintSummary()
+
+		if reportPath != "" {
+			if errReport := report.WriteJSON(reportPath); errReport != nil {
+				fmt.Println("写入转表报告失败 Err:", errReport)
+			}
+		}
+	}()
+
 	timeGenerate := time.Now()
-	errGenerate := excel_to_proto.ReadDirToGenerateProto(protoIdGen, confPath, ProtoPath, csPath, protoVersionData)
+	errGenerate := excel_to_proto.ReadDirToGenerateProto(protoIdGen, config.NewTableCache(confPath), ProtoPath, csPath, protoVersionData, nil, report)
 	fmt.Println("生成Proto耗时：", time.Since(timeGenerate))
 
-	if errGenerate != nil {
-		fmt.Printf("生成proto失败 Err:%v ", errGenerate)
+	//有表格失败时也保存，已经写出的proto使用了这次分配的ProtoID
+	if errSave := ProtoIDGen.SaveGen(protoIdGen, idGenPath); errSave != nil {
+		fmt.Println(errSave)
 		return
 	}
 
-	fmt.Println("--------proto生成结束--------")
-
-	ProtoIDGen.SaveGen(protoIdGen, idGenPath)
-
-	jsonBytes, errJson := json.Marshal(protoVersionData)
+	if errWrite := excel_to_proto.SaveProtoVersion(ProtoPath, protoVersionData); errWrite != nil {
+		fmt.Printf("写入protoVersionData报错: %v", errWrite)
+		return
+	}
 
-	if errJson != nil {
-		fmt.Printf("序列化protoVersionData报错 json.Marshal: %v", errJson)
+	if errGenerate != nil {
+		os.Stderr.WriteString(fmt.Sprintf("生成proto失败 Err:%v\n", errGenerate))
 		return
 	}
 
-	if errWrite := conf_tool.WriteFile(protoVersionPath, jsonBytes); errWrite != nil {
-		fmt.Printf("写入protoVersionData报错 conf_tool.WriteFile: %v", errWrite)
+	fmt.Println("--------proto生成结束--------")
+
+	if errConst := MatchConstGen.GenerateMatchConst(confPath, csConstPath, goConstPath, goConstPackage); errConst != nil {
+		fmt.Printf("生成match常量失败 Err:%v ", errConst)
 		return
 	}
 
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"Tool-Library/components/ProtoIDGen"
+	"Tool-Library/components/SqliteDBGen"
+	excel_to_proto "Tool-Library/components/excel-to-proto"
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+)
+
+// excel-to-db cache verify [flags]   检查缓存记录，有无效记录时退出码为1，不修改任何文件
+// excel-to-db cache rebuild [flags]  删除缓存记录和中断时留下的临时文件，然后完整生成
+// excel-to-db cache prune [flags]    清理已经删除的表格和页签留下的proto、数据库和缓存记录，--dryRun只列出，--quarantine移动到隔离目录
+const (
+	cacheVerify  = "verify"
+	cacheRebuild = "rebuild"
+	cachePrune   = "prune"
+)
+
+// parseCacheCommand 命令行第一个参数是cache时返回子命令和剩下的参数
+func parseCacheCommand(args []string) (string, []string, error) {
+	if len(args) == 0 || args[0] != "cache" {
+		return "", args, nil
+	}
+
+	if len(args) < 2 || (args[1] != cacheVerify && args[1] != cacheRebuild && args[1] != cachePrune) {
+		return "", nil, errors.Errorf("用法：excel-to-db cache verify|rebuild|prune [参数]")
+	}
+
+	return args[1], args[2:], nil
+}
+
+// verifyCache 检查ProtoID记录能否读取，ProtoVersion和DBVersion中的文件是否存在、内容是否一致
+func verifyCache(protoPath string, dbPath string) bool {
+	ok := true
+
+	idGenPath := protoPath + "proto_id.yaml"
+	if _, errStat := os.Stat(idGenPath); errStat == nil {
+		if _, errGen := ProtoIDGen.LoadGen(idGenPath); errGen != nil {
+			fmt.Println("ProtoID记录无法读取：", errGen)
+			ok = false
+		}
+	}
+
+	protoVersionData, errProto := excel_to_proto.LoadProtoVersion(protoPath)
+	if errProto != nil {
+		fmt.Println(errProto)
+		ok = false
+	} else {
+		for _, invalid := range excel_to_proto.ValidateProtoVersion(protoPath, protoVersionData) {
+			fmt.Println("ProtoVersion记录无效：", invalid)
+			ok = false
+		}
+	}
+
+	DBVersionData, errDB := SqliteDBGen.LoadDBVersion(dbPath)
+	if errDB != nil {
+		fmt.Println(errDB)
+		ok = false
+	} else {
+		//没有记录的数据库是旧版本或者中断时生成的，不影响转表
+		referenced := map[string]struct{}{}
+		for _, version := range DBVersionData {
+			referenced[SqliteDBGen.DBFileName(version.DB.FileName)] = struct{}{}
+		}
+
+		for _, invalid := range SqliteDBGen.ValidateDBVersion(dbPath, DBVersionData) {
+			fmt.Println("DBVersion记录无效：", invalid)
+			ok = false
+		}
+
+		dbFiles, _ := filepath.Glob(dbPath + "*.db")
+		for _, dbFile := range dbFiles {
+			if _, exist := referenced[SqliteDBGen.DBFileName(dbFile)]; !exist {
+				fmt.Println("没有缓存记录的数据库：", dbFile)
+			}
+		}
+	}
+
+	for _, tmpFile := range tempFiles(protoPath, dbPath) {
+		fmt.Println("中断时留下的临时文件：", tmpFile)
+	}
+
+	if ok {
+		fmt.Println("缓存记录全部有效")
+	}
+
+	return ok
+}
+
+// removeCache 删除ProtoVersion和DBVersion，ProtoID记录不是缓存，删除后字段ID会变化，保留
+func removeCache(protoPath string, dbPath string) error {
+	files := append([]string{protoPath + excel_to_proto.ProtoVersionName, dbPath + SqliteDBGen.DBVersionName}, tempFiles(protoPath, dbPath)...)
+
+	for _, file := range files {
+		if errRemove := os.Remove(file); errRemove != nil && !os.IsNotExist(errRemove) {
+			return errors.Errorf("删除缓存失败 %v", errRemove)
+		}
+		fmt.Println("删除：", file)
+	}
+
+	return nil
+}
+
+// tempFiles 写数据库和原子写入时的临时文件，正常结束时已经改名或删除
+func tempFiles(dirs ...string) []string {
+	var files []string
+	for _, dir := range dirs {
+		matches, _ := filepath.Glob(dir + "*.tmp")
+		files = append(files, matches...)
+	}
+
+	return files
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"Tool-Library/components/BuildCache"
+	"Tool-Library/components/BuildReport"
+	"Tool-Library/components/ColumnType"
+	"Tool-Library/components/ProtoIDGen"
 	"Tool-Library/components/SqliteDBGen"
 	"Tool-Library/components/VersionTxtGen"
+	"Tool-Library/components/WorkerPool"
+	"Tool-Library/components/XlsxAnnotate"
 	excel_to_proto "Tool-Library/components/excel-to-proto"
 	"Tool-Library/components/filemode"
+	"Tool-Library/shared/config"
 	"flag"
 	"fmt"
+	"github.com/pkg/errors"
 	"os"
 	"time"
 )
@@ -24,7 +32,94 @@ func main() {
 
 	joinPath := "1/"
 	flag.StringVar(&joinPath, "joinPath", genDBPath, "参与路径")
-	flag.Parse()
+
+	timeZone := "Local"
+	flag.StringVar(&timeZone, "timezone", timeZone, "datetime/date 默认时区，例如 Asia/Shanghai、+08:00")
+
+	flag.BoolVar(&ColumnType.TimeAsSeconds, "timeAsSeconds", ColumnType.TimeAsSeconds, "时间类型生成int64秒，否则生成google.protobuf.Timestamp/Duration")
+
+	reportPath := ""
+	flag.StringVar(&reportPath, "report", reportPath, "转表报告JSON路径（所有错误和警告），为空不写入")
+
+	annotatePath := ""
+	flag.StringVar(&annotatePath, "annotate", annotatePath, "转表失败时把标记了错误单元格的表格副本写到这个目录，为空不生成")
+
+	flag.Func("protoCase", "proto字段名命名风格 keep snake camel pascal，默认keep", func(s string) error {
+		return config.Naming.SetCase(config.OutputProto, s)
+	})
+
+	flag.StringVar(&config.Naming.KeywordSuffix, "keywordSuffix", config.Naming.KeywordSuffix, "字段名和关键字重名时加的后缀")
+
+	flag.BoolVar(&ProtoIDGen.PackagePerWorkbook, "packagePerWorkbook", ProtoIDGen.PackagePerWorkbook, "每个表格生成单独的proto package（conf.表格名），C# namespace和Go package一致")
+	flag.BoolVar(&ProtoIDGen.NestedMessage, "nestedMessage", ProtoIDGen.NestedMessage, "标题中用点分隔的列生成嵌套消息（pos.x、drops.0.id），不开启时生成普通字段（pos_x）")
+
+	flag.IntVar(&WorkerPool.Jobs, "jobs", WorkerPool.Jobs, "同时处理的表格数量，0使用CPU核数")
+
+	packVersion := ""
+	flag.StringVar(&packVersion, "packVersion", packVersion, "写到version.txt的打包版本号，为空不写")
+
+	signKeyPath := ""
+	flag.StringVar(&signKeyPath, "signKey", signKeyPath, "ed25519签名私钥路径（PEM PKCS8或base64），为空时version.txt不签名")
+
+	verify := false
+	flag.BoolVar(&verify, "verify-reproducible", verify, "在临时目录完整生成两次，检查数据库和version.txt是否完全一致，不修改gen目录，不一致时退出码为1")
+
+	pruneDryRun := false
+	flag.BoolVar(&pruneDryRun, "dryRun", pruneDryRun, "cache prune 只列出要清理的文件和缓存记录，不修改")
+
+	quarantinePath := ""
+	flag.StringVar(&quarantinePath, "quarantine", quarantinePath, "cache prune 把要清理的文件移动到这个目录，为空直接删除")
+
+	flag.BoolVar(&config.DefaultCellOption.TrimSpace, "trimSpace", config.DefaultCellOption.TrimSpace, "读取单元格时去掉首尾空白")
+	flag.BoolVar(&config.DefaultCellOption.FillMerged, "fillMerged", config.DefaultCellOption.FillMerged, "合并单元格的值填充到整个合并区域，否则只有左上角有值")
+	flag.BoolVar(&config.DefaultCellOption.ExcelPrecision, "excelPrecision", config.DefaultCellOption.ExcelPrecision, "小数按Excel显示的15位有效数字读取，去掉公式结果的浮点误差")
+
+	cacheCommand, args, errCommand := parseCacheCommand(os.Args[1:])
+	if errCommand != nil {
+		fmt.Println(errCommand)
+		os.Exit(2)
+	}
+
+	flag.CommandLine.Parse(args)
+
+	//失败时退出码为1，CI和conf-http按退出码判断，先执行其他defer（解锁、输出转表报告）再退出
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	if errTimeZone := ColumnType.SetTimeZone(timeZone); errTimeZone != nil {
+		fmt.Println("时区设置失败 Err:", errTimeZone)
+		exitCode = 2
+		return
+	}
+
+	manifest, errManifest := manifestInfo(confPath, packVersion, signKeyPath)
+	if errManifest != nil {
+		fmt.Println(errManifest)
+		exitCode = 2
+		return
+	}
+
+	if verify {
+		diffs, errVerify := verifyReproducible(confPath, ProtoPath+"proto_id.yaml", joinPath, packVersion, signKeyPath)
+		if errVerify != nil {
+			os.Stderr.WriteString("检查生成结果失败 Err: " + errVerify.Error() + "\n")
+			exitCode = 1
+			return
+		}
+
+		if len(diffs) > 0 {
+			os.Stderr.WriteString(fmt.Sprintln("两次生成的结果不一致：", diffs))
+			exitCode = 1
+			return
+		}
+
+		fmt.Println("两次生成的数据库和version.txt完全一致")
+		return
+	}
 
 	fmt.Println("数据库生成路径：", genDBPath)
 	fmt.Println("配置表路径：", confPath)
@@ -34,20 +129,86 @@ func main() {
 	errorMkdir := filemode.MkdirAll(genPath, os.ModePerm)
 	if errorMkdir != nil {
 		fmt.Println("创建gen目录失败 Err:", errorMkdir)
+		exitCode = 1
 		return
 	}
 
 	errorMkdir = filemode.MkdirAll(ProtoPath, os.ModePerm)
 	if errorMkdir != nil {
 		fmt.Println("创建ProtoPath目录失败 Err:", errorMkdir)
+		exitCode = 1
 		return
 	}
 
+	//同一个gen目录同时只有一个转表进程，conf-http同时收到多个请求时排队
+	for _, dir := range []string{ProtoPath, genDBPath} {
+		lock, errLock := BuildCache.LockDir(dir)
+		if errLock != nil {
+			fmt.Println("锁定gen目录失败 Err:", errLock)
+			exitCode = 1
+			return
+		}
+		defer lock.Unlock()
+	}
+
+	switch cacheCommand {
+	case cacheVerify:
+		if !verifyCache(ProtoPath, genDBPath) {
+			exitCode = 1
+		}
+		return
+	case cachePrune:
+		if errPrune := pruneCache(confPath, ProtoPath, genDBPath, quarantinePath, pruneDryRun); errPrune != nil {
+			fmt.Println("清理失败：", errPrune)
+			exitCode = 1
+		}
+		return
+	case cacheRebuild:
+		fmt.Println("删除缓存记录，完整生成")
+		if errRemove := removeCache(ProtoPath, genDBPath); errRemove != nil {
+			fmt.Println(errRemove)
+			exitCode = 1
+			return
+		}
+	}
+
+	report := BuildReport.New()
+	defer func() {
+		report.PrintSummary()
+
+		if report.ErrorCount() > 0 {
+			exitCode = 1
+		}
+
+		if reportPath != "" {
+			if errReport := report.WriteJSON(reportPath); errReport != nil {
+				fmt.Println("写入转表报告失败 Err:", errReport)
+			}
+		}
+
+		if annotatePath != "" && report.ErrorCount() > 0 {
+			files, errAnnotate := XlsxAnnotate.WriteAnnotated(confPath, annotatePath, report)
+			if errAnnotate != nil {
+				fmt.Println("生成标记表格失败 Err:", errAnnotate)
+			}
+			fmt.Println("标记错误的表格：", files)
+		}
+	}()
+
+	//每个表格只读取和解析一次，生成proto和数据库共用
+	tables := config.NewTableCache(confPath)
+
 	timeGenProto := time.Now()
 	//转表生成proto
-	if errExcelToProto := excel_to_proto.GenerateExcelToProto(confPath, ProtoPath+"proto_id.yaml", ProtoPath); errExcelToProto != nil {
-		fmt.Println("转表生成proto失败 ExcelToProtoGen.GenerateExcelToProto Err: ", errExcelToProto)
-		return
+	schemas, errExcelToProto := excel_to_proto.GenerateExcelToProto(tables, ProtoPath+"proto_id.yaml", ProtoPath, report)
+	if errExcelToProto != nil {
+		os.Stderr.WriteString("转表生成proto失败 ExcelToProtoGen.GenerateExcelToProto Err: " + errExcelToProto.Error() + "\n")
+
+		//只有表格错误时继续生成数据库，把数据错误也一起检查出来，消息名重复时数据库也会互相覆盖
+		exitCode = 1
+		if schemas == nil || report.ErrorCount() == 0 || errors.Is(errExcelToProto, excel_to_proto.ErrMessageNameConflict) {
+			return
+		}
 	}
 	costTimeGenProto := time.Since(timeGenProto)
 
@@ -55,20 +216,26 @@ func main() {
 
 	timeDB := time.Now()
 	//生成数据库
-	errDB := SqliteDBGen.GenerateSqliteDB(confPath, ProtoPath, genDBPath, joinPath, &allDbVersion)
+	errDB := SqliteDBGen.GenerateSqliteDB(tables, schemas, genDBPath, joinPath, &allDbVersion, report)
 	costTimeDB := time.Since(timeDB)
 
 	if errDB != nil {
 		os.Stderr.WriteString("生成数据库失败,Err：" + errDB.Error() + "\n")
+		exitCode = 1
+		return
+	}
+
+	if errExcelToProto != nil {
 		return
 	}
 
 	//生成版本号文件
 	timeVersion := time.Now()
-	errVersion := VersionTxtGen.GenerateVersionFile(genDBPath, allDbVersion)
+	errVersion := VersionTxtGen.GenerateVersionFile(genDBPath, allDbVersion, manifest)
 	costTimeVersion := time.Since(timeVersion)
 	if errVersion != nil {
 		fmt.Println("生成版本号文件失败：Err", errVersion)
+		exitCode = 1
 		return
 	}
 
@@ -78,3 +245,24 @@ func main() {
 
 	fmt.Println("总体时间：", time.Since(startTime))
 }
+
+// manifestInfo version.txt的打包版本号、生成时间和签名私钥
+func manifestInfo(confPath string, packVersion string, signKeyPath string) (VersionTxtGen.ManifestInfo, error) {
+	info := VersionTxtGen.ManifestInfo{PackVersion: packVersion}
+
+	createdAt, errTime := VersionTxtGen.BuildTime(confPath)
+	if errTime != nil {
+		return info, errTime
+	}
+	info.CreatedAt = createdAt
+
+	if signKeyPath != "" {
+		signKey, errKey := VersionTxtGen.LoadSignKey(signKeyPath)
+		if errKey != nil {
+			return info, errKey
+		}
+		info.SignKey = signKey
+	}
+
+	return info, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"Tool-Library/components/ProtoIDGen"
+	"Tool-Library/components/SqliteDBGen"
+	"Tool-Library/components/VersionTxtGen"
+	excel_to_proto "Tool-Library/components/excel-to-proto"
+	"Tool-Library/shared/config"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// prunePlan 配置目录中已经没有的表格和页签留下的文件和缓存记录
+type prunePlan struct {
+	// key=要清理的文件 value=所在的gen目录，隔离时保留相对这个目录的路径
+	files map[string]string
+
+	protoVersionData map[string]excel_to_proto.ProtoVersion
+	DBVersionData    map[string]SqliteDBGen.SheetDBVersion
+
+	// 要删除的缓存记录，只用来输出
+	entries []string
+}
+
+// pruneCache 按配置目录中当前的表格和list页签计算仍在使用的proto、数据库和缓存记录，其他的删除或者移动到隔离目录
+// 所有版本文件（version.txt和conf-http改名后的version_*.txt）中的数据库也保留，客户端可能还没有更新；dryRun只列出不修改
+func pruneCache(confPath string, protoPath string, dbPath string, quarantinePath string, dryRun bool) error {
+	plan, errPlan := planPrune(confPath, protoPath, dbPath)
+	if errPlan != nil {
+		return errPlan
+	}
+
+	files := make([]string, 0, len(plan.files))
+	for file := range plan.files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, entry := range plan.entries {
+		fmt.Println("清理缓存记录：", entry)
+	}
+
+	for _, file := range files {
+		fmt.Println("清理文件：", file)
+	}
+
+	fmt.Printf("清理文件：%d 缓存记录：%d\n", len(files), len(plan.entries))
+
+	if dryRun {
+		fmt.Println("dryRun 只列出，不修改")
+		return nil
+	}
+
+	//先更新缓存再清理文件，中途退出时只会多留下文件，下次清理
+	if errSave := excel_to_proto.SaveProtoVersion(protoPath, plan.protoVersionData); errSave != nil {
+		return errors.Errorf("写入ProtoVersion失败 %v", errSave)
+	}
+
+	if errSave := SqliteDBGen.SaveDBVersion(dbPath, plan.DBVersionData); errSave != nil {
+		return errors.Errorf("写入DBVersion失败 %v", errSave)
+	}
+
+	quarantineDir := ""
+	if quarantinePath != "" {
+		quarantineDir = filepath.Join(quarantinePath, time.Now().Format("20060102-150405"))
+		fmt.Println("移动到隔离目录：", quarantineDir)
+	}
+
+	for _, file := range files {
+		if quarantineDir == "" {
+			if errRemove := os.Remove(file); errRemove != nil && !os.IsNotExist(errRemove) {
+				return errors.Errorf("删除文件失败 %v", errRemove)
+			}
+			continue
+		}
+
+		rel, errRel := filepath.Rel(plan.files[file], file)
+		if errRel != nil {
+			return errors.Errorf("计算隔离路径失败 %v", errRel)
+		}
+
+		target := filepath.Join(quarantineDir, filepath.Base(filepath.Clean(plan.files[file])), rel)
+		if errMkdir := os.MkdirAll(filepath.Dir(target), os.ModePerm); errMkdir != nil {
+			return errors.Errorf("创建隔离目录失败 %v", errMkdir)
+		}
+
+		if errRename := os.Rename(file, target); errRename != nil {
+			return errors.Errorf("移动文件失败，隔离目录需要和gen目录在同一个磁盘 %v", errRename)
+		}
+	}
+
+	return nil
+}
+
+func planPrune(confPath string, protoPath string, dbPath string) (*prunePlan, error) {
+	plan := &prunePlan{files: map[string]string{}}
+
+	//缓存损坏时无法判断哪些文件还在使用，先执行 cache rebuild
+	protoVersionData, errProto := excel_to_proto.LoadProtoVersion(protoPath)
+	if errProto != nil {
+		return nil, errors.Errorf("%v，先执行 cache rebuild", errProto)
+	}
+	plan.protoVersionData = protoVersionData
+
+	DBVersionData, errDB := SqliteDBGen.LoadDBVersion(dbPath)
+	if errDB != nil {
+		return nil, errors.Errorf("%v，先执行 cache rebuild", errDB)
+	}
+	plan.DBVersionData = DBVersionData
+
+	tables := config.NewTableCache(confPath)
+
+	fss, errWalk := config.WalkDir(tables.Dir())
+	if errWalk != nil {
+		return nil, errors.Errorf("读取配置目录失败 %v", errWalk)
+	}
+
+	// key=表格名（不带后缀） value=当前的页签
+	liveSheets := map[string]map[string]struct{}{}
+	liveProtos := map[string]struct{}{}
+	liveCacheIDs := map[string]struct{}{}
+
+	for _, fName := range fss {
+		if !config.IsTableFile(fName) {
+			continue
+		}
+
+		//有表格读取失败时不清理，否则会把它的文件也当成没有使用
+		sheetNames, errList := excel_to_proto.ListSheets(tables, fName)
+		if errList != nil {
+			return nil, errors.Errorf("读取表格失败 %v %v", fName, errList)
+		}
+
+		filenameOnly := strings.TrimSuffix(fName, filepath.Ext(fName))
+		liveSheets[filenameOnly] = map[string]struct{}{}
+
+		for _, sheetName := range sheetNames {
+			liveSheets[filenameOnly][sheetName] = struct{}{}
+			liveProtos[filepath.Clean(protoPath+ProtoIDGen.GetProtoFileName(filenameOnly, sheetName))] = struct{}{}
+			liveCacheIDs[SqliteDBGen.SheetCacheID(fName, sheetName)] = struct{}{}
+		}
+
+		tables.Release(fName)
+	}
+
+	for filenameOnly, version := range protoVersionData {
+		sheets, exist := liveSheets[filenameOnly]
+		if !exist {
+			delete(protoVersionData, filenameOnly)
+			plan.entries = append(plan.entries, excel_to_proto.ProtoVersionName+" "+filenameOnly)
+			continue
+		}
+
+		for sheetName := range version.SheetKeys {
+			if _, live := sheets[sheetName]; !live {
+				delete(version.SheetKeys, sheetName)
+				delete(version.ProtoName, ProtoIDGen.GetProtoFileName(filenameOnly, sheetName))
+				plan.entries = append(plan.entries, excel_to_proto.ProtoVersionName+" "+filenameOnly+ProtoIDGen.KeySep+sheetName)
+			}
+		}
+	}
+
+	liveDBs := map[string]struct{}{}
+	for cacheID, version := range DBVersionData {
+		if _, live := liveCacheIDs[cacheID]; !live {
+			delete(DBVersionData, cacheID)
+			plan.entries = append(plan.entries, SqliteDBGen.DBVersionName+" "+cacheID)
+			continue
+		}
+
+		liveDBs[SqliteDBGen.DBFileName(version.DB.FileName)] = struct{}{}
+	}
+
+	versionDBs, errVersion := readVersionDBs(dbPath)
+	if errVersion != nil {
+		return nil, errVersion
+	}
+	for _, dbName := range versionDBs {
+		liveDBs[dbName] = struct{}{}
+	}
+
+	sort.Strings(plan.entries)
+
+	//生成的proto都以confpb开头，按表格分package时在子目录中
+	errWalkProto := filepath.WalkDir(protoPath, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !strings.HasPrefix(d.Name(), "confpb") || filepath.Ext(d.Name()) != ".proto" {
+			return nil
+		}
+
+		if _, live := liveProtos[filepath.Clean(file)]; !live {
+			plan.files[file] = protoPath
+		}
+
+		return nil
+	})
+	if errWalkProto != nil && !os.IsNotExist(errWalkProto) {
+		return nil, errors.Errorf("读取proto目录失败 %v", errWalkProto)
+	}
+
+	dbFiles, _ := filepath.Glob(dbPath + "*.db")
+	for _, dbFile := range dbFiles {
+		if _, live := liveDBs[SqliteDBGen.DBFileName(dbFile)]; !live {
+			plan.files[dbFile] = dbPath
+		}
+	}
+
+	for _, tmpFile := range tempFiles(protoPath, dbPath) {
+		plan.files[tmpFile] = filepath.Dir(tmpFile)
+	}
+
+	return plan, nil
+}
+
+// readVersionDBs 目录中所有版本文件的数据库文件名，没有版本文件时返回空
+// conf-http发布后把version.txt改名为 version_md5_size.txt，旧版本的客户端和 /client/diff 还会读取这些文件，它们的数据库都保留
+func readVersionDBs(dbPath string) ([]string, error) {
+	versionFiles, _ := filepath.Glob(dbPath + "version*.txt")
+
+	var dbNames []string
+	for _, versionFile := range versionFiles {
+		data, errRead := os.ReadFile(versionFile)
+		if errRead != nil {
+			return nil, errors.Errorf("读取版本文件失败 %v", errRead)
+		}
+
+		version := VersionTxtGen.VersionText{}
+		if errJson := json.Unmarshal(data, &version); errJson != nil {
+			return nil, errors.Errorf("解析版本文件失败 %v %v", versionFile, errJson)
+		}
+
+		for _, cell := range version.CellList {
+			dbNames = append(dbNames, SqliteDBGen.DBFileName(cell.FileName))
+		}
+	}
+
+	return dbNames, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"Tool-Library/components/BuildCache"
+	"Tool-Library/components/SqliteDBGen"
+	"Tool-Library/components/VersionTxtGen"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPlanPrune(t *testing.T) {
+	root := filepath.ToSlash(t.TempDir()) + "/"
+	confPath := root + "conf/"
+	protoPath := root + "proto/"
+	dbPath := root + "db/"
+
+	writeFile := func(file string, data []byte) {
+		if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, data, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeJson := func(file string, v interface{}) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(file, data)
+	}
+
+	writeFile(confPath+"Hero.tsv", []byte("id\nid\nint\ncs\n\n1\n"))
+
+	// 记录中的FileName带joinPath，和gen目录中的文件名比较
+	key := BuildCache.Key{Generator: BuildCache.GeneratorVersion}
+	writeJson(dbPath+SqliteDBGen.DBVersionName, map[string]SqliteDBGen.SheetDBVersion{
+		SqliteDBGen.SheetCacheID("Hero.tsv", "Data"): {Key: key, DB: VersionTxtGen.MsgToDB{FileName: "4/Hero_Data_live.db"}},
+		SqliteDBGen.SheetCacheID("Old.tsv", "Data"):  {Key: key, DB: VersionTxtGen.MsgToDB{FileName: "4/Old_Data_stale.db"}},
+	})
+
+	// 当前version.txt中的数据库客户端可能还在使用，保留
+	writeJson(dbPath+"version.txt", VersionTxtGen.VersionText{CellList: []VersionTxtGen.MsgToDB{{FileName: "4/Old_Data_published.db"}}})
+
+	// conf-http发布后改名的旧版本文件，旧版本的客户端还在使用
+	writeJson(dbPath+"version_0123abcd_42.txt", VersionTxtGen.VersionText{CellList: []VersionTxtGen.MsgToDB{{FileName: "3/Old_Data_renamed.db"}}})
+
+	for _, name := range []string{"Hero_Data_live.db", "Old_Data_stale.db", "Old_Data_published.db", "Old_Data_renamed.db", "Stray.db"} {
+		writeFile(dbPath+name, []byte(name))
+	}
+	writeFile(protoPath+"confpbHeroData.proto", nil)
+	writeFile(protoPath+"confpbOldData.proto", nil)
+
+	plan, err := planPrune(confPath, protoPath, dbPath)
+	if err != nil {
+		t.Fatalf("planPrune error %v", err)
+	}
+
+	var files []string
+	for file := range plan.files {
+		files = append(files, filepath.ToSlash(file))
+	}
+	sort.Strings(files)
+
+	wantFiles := []string{dbPath + "Old_Data_stale.db", dbPath + "Stray.db", protoPath + "confpbOldData.proto"}
+	sort.Strings(wantFiles)
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("files = %v, want %v", files, wantFiles)
+	}
+
+	if _, exist := plan.DBVersionData[SqliteDBGen.SheetCacheID("Hero.tsv", "Data")]; !exist || len(plan.DBVersionData) != 1 {
+		t.Errorf("DBVersionData = %v, want only Hero.tsv#Data", plan.DBVersionData)
+	}
+
+	wantEntries := []string{SqliteDBGen.DBVersionName + " " + SqliteDBGen.SheetCacheID("Old.tsv", "Data")}
+	if !reflect.DeepEqual(plan.entries, wantEntries) {
+		t.Errorf("entries = %v, want %v", plan.entries, wantEntries)
+	}
+}
+
+func TestDBFileName(t *testing.T) {
+	tests := map[string]string{
+		"Hero_Data_x.db":        "Hero_Data_x.db",
+		"4/Hero_Data_x.db":      "Hero_Data_x.db",
+		"conf/4/Hero_Data_x.db": "Hero_Data_x.db",
+	}
+
+	for in, want := range tests {
+		if got := SqliteDBGen.DBFileName(in); got != want {
+			t.Errorf("DBFileName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"Tool-Library/components/BuildReport"
+	"Tool-Library/components/SqliteDBGen"
+	"Tool-Library/components/VersionTxtGen"
+	excel_to_proto "Tool-Library/components/excel-to-proto"
+	"Tool-Library/shared/config"
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// verifyReproducible 用同样的表格和ProtoID记录在临时目录完整生成两次，比较数据库和version.txt是否完全一致
+// 不修改gen目录，返回不一致的文件；每次生成单独计算version.txt的生成时间和签名，和正常转表一样
+func verifyReproducible(confPath string, idGenPath string, joinPath string, packVersion string, signKeyPath string) ([]string, error) {
+	idData, errRead := os.ReadFile(idGenPath)
+	if errRead != nil && !os.IsNotExist(errRead) {
+		return nil, errors.Errorf("读取ProtoID记录失败 %v", errRead)
+	}
+
+	tmpDir, errTmp := os.MkdirTemp("", "excel-to-db-verify-")
+	if errTmp != nil {
+		return nil, errors.Errorf("创建临时目录失败 %v", errTmp)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	//两次都在同一个目录生成，proto路径会写到go_package中，也是缓存key的一部分
+	buildPath := filepath.ToSlash(filepath.Join(tmpDir, "build")) + "/"
+
+	var dbPaths []string
+	for i := 0; i < 2; i++ {
+		fmt.Println("--------第", i+1, "次生成：", buildPath, "--------")
+
+		manifest, errManifest := manifestInfo(confPath, packVersion, signKeyPath)
+		if errManifest != nil {
+			return nil, errManifest
+		}
+
+		if errBuild := buildOnce(confPath, idData, buildPath, joinPath, manifest); errBuild != nil {
+			return nil, errBuild
+		}
+
+		dbPath := filepath.ToSlash(filepath.Join(tmpDir, fmt.Sprint(i))) + "/"
+		if errRename := os.Rename(buildPath+"db/", dbPath); errRename != nil {
+			return nil, errors.Errorf("移动生成结果失败 %v", errRename)
+		}
+
+		if errRemove := os.RemoveAll(buildPath); errRemove != nil {
+			return nil, errors.Errorf("删除临时目录失败 %v", errRemove)
+		}
+
+		dbPaths = append(dbPaths, dbPath)
+	}
+
+	return compareDir(dbPaths[0], dbPaths[1])
+}
+
+// buildOnce 从空目录开始生成proto、数据库和version.txt，ProtoID记录使用idData的副本
+func buildOnce(confPath string, idData []byte, buildPath string, joinPath string, manifest VersionTxtGen.ManifestInfo) error {
+	protoPath := buildPath + "proto/"
+	if errMkdir := os.MkdirAll(protoPath, os.ModePerm); errMkdir != nil {
+		return errors.Errorf("创建目录失败 %v", errMkdir)
+	}
+
+	if errWrite := os.WriteFile(protoPath+"proto_id.yaml", idData, 0666); errWrite != nil {
+		return errors.Errorf("复制ProtoID记录失败 %v", errWrite)
+	}
+
+	report := BuildReport.New()
+	tables := config.NewTableCache(confPath)
+
+	schemas, errProto := excel_to_proto.GenerateExcelToProto(tables, protoPath+"proto_id.yaml", protoPath, report)
+	if errProto != nil {
+		return errors.Errorf("生成proto失败 %v", errProto)
+	}
+
+	dbPath := buildPath + "db/"
+	allDbVersion := []VersionTxtGen.MsgToDB{}
+	if errDB := SqliteDBGen.GenerateSqliteDB(tables, schemas, dbPath, joinPath, &allDbVersion, report); errDB != nil {
+		return errors.Errorf("生成数据库失败 %v", errDB)
+	}
+
+	if report.ErrorCount() > 0 {
+		report.PrintSummary()
+		return errors.Errorf("表格有错误，无法检查")
+	}
+
+	return VersionTxtGen.GenerateVersionFile(dbPath, allDbVersion, manifest)
+}
+
+// compareDir 比较两个目录中的数据库和version.txt，DBVersion.json是缓存记录不比较
+func compareDir(dirA string, dirB string) ([]string, error) {
+	names := map[string]struct{}{}
+	for _, dir := range []string{dirA, dirB} {
+		entries, errRead := os.ReadDir(dir)
+		if errRead != nil {
+			return nil, errors.Errorf("读取目录失败 %v", errRead)
+		}
+
+		for _, entry := range entries {
+			if entry.Name() == SqliteDBGen.DBVersionName {
+				continue
+			}
+			names[entry.Name()] = struct{}{}
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var diffs []string
+	for _, name := range sorted {
+		dataA, errA := os.ReadFile(dirA + name)
+		dataB, errB := os.ReadFile(dirB + name)
+		if errA != nil || errB != nil || !bytes.Equal(dataA, dataB) {
+			diffs = append(diffs, name)
+		}
+	}
+
+	return diffs, nil
+}
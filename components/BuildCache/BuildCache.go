@@ -0,0 +1,178 @@
+package BuildCache
+
+import (
+	"Tool-Library/components/md5"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GeneratorVersion 生成proto或数据库的方式变化时修改，升级转表工具后所有页签重新生成
+const GeneratorVersion = "3"
+
+// Key 一个页签的缓存key，分开记录每一部分，重新生成时可以输出原因
+type Key struct {
+	Generator string
+
+	// 页签单元格内容，见 ContentDigest
+	Content string `json:",omitempty"`
+
+	// 页签生成的proto内容，ProtoID、命名规则、package规则变化时都会变化
+	Schema string
+
+	// 影响生成结果的转表选项和match表
+	Options string `json:",omitempty"`
+}
+
+func NewKey(content string, schema string, options string) Key {
+	return Key{Generator: GeneratorVersion, Content: content, Schema: schema, Options: options}
+}
+
+// String 所有部分一起的md5，用作生成文件名
+func (k Key) String() string {
+	return Digest(k.Generator, k.Content, k.Schema, k.Options)
+}
+
+// Reason 和缓存记录比较，一致时返回空字符串
+func (k Key) Reason(old Key) string {
+	switch {
+	case old == Key{}:
+		return "没有缓存记录"
+	case k.Generator != old.Generator:
+		return "转表工具版本变化"
+	case k.Schema != old.Schema:
+		return "proto结构变化"
+	case k.Content != old.Content:
+		return "页签内容变化"
+	case k.Options != old.Options:
+		return "转表选项或match表变化"
+	}
+
+	return ""
+}
+
+// Digest 多个字符串按长度拼接后的md5，不同的分段方式不会得到同样的结果
+func Digest(parts ...string) string {
+	builder := strings.Builder{}
+	for _, part := range parts {
+		builder.WriteString(strconv.Itoa(len(part)))
+		builder.WriteString(":")
+		builder.WriteString(part)
+	}
+
+	return md5.String([]byte(builder.String()))
+}
+
+// ContentDigest 页签单元格内容的md5，行尾的空单元格和表尾的空行不影响结果
+// 只改了表格中其他页签、样式或者另存为时不会变化
+func ContentDigest(rows [][]string) string {
+	end := len(rows)
+	for end > 0 && rowLen(rows[end-1]) == 0 {
+		end--
+	}
+
+	builder := strings.Builder{}
+	for _, row := range rows[:end] {
+		n := rowLen(row)
+		builder.WriteString(strconv.Itoa(n))
+		builder.WriteString("\n")
+
+		for _, cell := range row[:n] {
+			builder.WriteString(strconv.Itoa(len(cell)))
+			builder.WriteString(":")
+			builder.WriteString(cell)
+		}
+	}
+
+	return md5.String([]byte(builder.String()))
+}
+
+func rowLen(row []string) int {
+	n := len(row)
+	for n > 0 && row[n-1] == "" {
+		n--
+	}
+
+	return n
+}
+
+// Miss 需要重新生成的页签
+type Miss struct {
+	File   string
+	Sheet  string
+	Reason string
+}
+
+// Stats 记录一次转表中缓存命中和重新生成的页签，并发安全
+type Stats struct {
+	name string
+
+	mux    sync.Mutex
+	hits   int
+	misses []Miss
+}
+
+func NewStats(name string) *Stats {
+	return &Stats{name: name}
+}
+
+func (s *Stats) Hit(file string, sheet string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.hits++
+}
+
+func (s *Stats) Miss(file string, sheet string, reason string) {
+	fmt.Println(s.name, "重新生成：", file, sheet, "原因：", reason)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.misses = append(s.misses, Miss{File: file, Sheet: sheet, Reason: reason})
+}
+
+func (s *Stats) Hits() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.hits
+}
+
+// Misses 按表格和页签排序
+func (s *Stats) Misses() []Miss {
+	s.mux.Lock()
+	misses := append([]Miss{}, s.misses...)
+	s.mux.Unlock()
+
+	sort.Slice(misses, func(i, j int) bool {
+		if misses[i].File != misses[j].File {
+			return misses[i].File < misses[j].File
+		}
+		return misses[i].Sheet < misses[j].Sheet
+	})
+
+	return misses
+}
+
+// PrintSummary 输出命中数量和按原因统计的重新生成数量
+func (s *Stats) PrintSummary() {
+	misses := s.Misses()
+
+	reasons := map[string]int{}
+	var names []string
+	for _, miss := range misses {
+		if reasons[miss.Reason] == 0 {
+			names = append(names, miss.Reason)
+		}
+		reasons[miss.Reason]++
+	}
+	sort.Strings(names)
+
+	fmt.Printf("%s缓存 命中：%d 重新生成：%d\n", s.name, s.Hits(), len(misses))
+	for _, name := range names {
+		fmt.Printf("  %s：%d\n", name, reasons[name])
+	}
+}
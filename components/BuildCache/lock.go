@@ -0,0 +1,63 @@
+package BuildCache
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+)
+
+// LockFileName 目录锁文件，锁由系统持有，进程退出后自动释放，文件本身不需要删除
+const LockFileName = ".lock"
+
+// Lock 转表目录的进程锁，同一个目录同时只有一个转表进程读写缓存和生成文件
+type Lock struct {
+	file *os.File
+}
+
+// LockDir 锁住目录，其他进程正在使用时等待它结束
+func LockDir(dir string) (*Lock, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, errors.Errorf("创建目录失败 %v %v", dir, err)
+	}
+
+	path := filepath.Join(dir, LockFileName)
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, errors.Errorf("打开锁文件失败 %v %v", path, err)
+	}
+
+	locked, err := tryLockFile(f)
+	if err != nil {
+		f.Close()
+		return nil, errors.Errorf("锁定目录失败 %v %v", dir, err)
+	}
+
+	if !locked {
+		fmt.Println("其他转表进程正在使用目录，等待结束：", dir)
+
+		if err = lockFile(f); err != nil {
+			f.Close()
+			return nil, errors.Errorf("锁定目录失败 %v %v", dir, err)
+		}
+	}
+
+	return &Lock{file: f}, nil
+}
+
+func (l *Lock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	errUnlock := unlockFile(l.file)
+	errClose := l.file.Close()
+	l.file = nil
+
+	if errUnlock != nil {
+		return errUnlock
+	}
+
+	return errClose
+}
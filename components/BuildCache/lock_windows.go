@@ -0,0 +1,31 @@
+//go:build windows
+
+package BuildCache
+
+import (
+	"golang.org/x/sys/windows"
+	"os"
+)
+
+func lockFileEx(f *os.File, flags uint32) error {
+	ol := new(windows.Overlapped)
+	return windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, ol)
+}
+
+func tryLockFile(f *os.File) (bool, error) {
+	err := lockFileEx(f, windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func lockFile(f *os.File) error {
+	return lockFileEx(f, windows.LOCKFILE_EXCLUSIVE_LOCK)
+}
+
+func unlockFile(f *os.File) error {
+	ol := new(windows.Overlapped)
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+}
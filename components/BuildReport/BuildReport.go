@@ -0,0 +1,187 @@
+package BuildReport
+
+import (
+	conf_tool "Tool-Library/components/conf-tool"
+	"Tool-Library/shared/config"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Entry 一条错误或者警告，Row Col 从1开始，0表示不是具体的单元格
+type Entry struct {
+	File     string `json:"file"`
+	Sheet    string `json:"sheet"`
+	Row      int    `json:"row"`
+	Col      int    `json:"col"`
+	Cell     string `json:"cell"` // A1坐标，例如 C17
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// Report 收集转表过程中所有表格的错误和警告，不会遇到第一个错误就停止
+type Report struct {
+	mux sync.Mutex
+
+	Entries []Entry `json:"entries"`
+}
+
+func New() *Report {
+	return &Report{}
+}
+
+func (r *Report) Add(e Entry) {
+	if e.Row > 0 && e.Col > 0 {
+		e.Cell = config.CellRef(e.Row-1, e.Col-1)
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	//proto和数据库两个阶段可能报同一个错误
+	for _, old := range r.Entries {
+		if old == e {
+			return
+		}
+	}
+
+	r.Entries = append(r.Entries, e)
+}
+
+func (r *Report) Error(file string, sheet string, row int, col int, format string, args ...interface{}) {
+	r.Add(Entry{
+		File:     file,
+		Sheet:    sheet,
+		Row:      row,
+		Col:      col,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *Report) Warning(file string, sheet string, row int, col int, format string, args ...interface{}) {
+	r.Add(Entry{
+		File:     file,
+		Sheet:    sheet,
+		Row:      row,
+		Col:      col,
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *Report) count(severity string) int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	n := 0
+	for _, e := range r.Entries {
+		if e.Severity == severity {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (r *Report) ErrorCount() int {
+	return r.count(SeverityError)
+}
+
+func (r *Report) WarningCount() int {
+	return r.count(SeverityWarning)
+}
+
+// Err 没有错误返回nil
+func (r *Report) Err() error {
+	if n := r.ErrorCount(); n > 0 {
+		return errors.Errorf("转表失败，共%d个错误，%d个警告", n, r.WarningCount())
+	}
+
+	return nil
+}
+
+// Sorted 按文件、页签、行、列排序，多线程收集的顺序不固定
+func (r *Report) Sorted() []Entry {
+	r.mux.Lock()
+	entries := append([]Entry{}, r.Entries...)
+	r.mux.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Sheet != b.Sheet {
+			return a.Sheet < b.Sheet
+		}
+		if a.Row != b.Row {
+			return a.Row < b.Row
+		}
+		return a.Col < b.Col
+	})
+
+	return entries
+}
+
+// PrintSummary 按表格分组输出
+func (r *Report) PrintSummary() {
+	entries := r.Sorted()
+	if len(entries) == 0 {
+		return
+	}
+
+	fmt.Println("\n--------转表报告--------")
+
+	group := ""
+	for _, e := range entries {
+		if g := e.File + " " + e.Sheet; g != group {
+			group = g
+			fmt.Println("[" + group + "]")
+		}
+
+		if e.Cell != "" {
+			fmt.Printf("  %s %s: %s\n", e.Severity, e.Cell, e.Message)
+		} else {
+			fmt.Printf("  %s: %s\n", e.Severity, e.Message)
+		}
+	}
+
+	fmt.Printf("错误：%d 警告：%d\n", r.ErrorCount(), r.WarningCount())
+	fmt.Println("--------转表报告结束--------")
+}
+
+func (r *Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(&Report{Entries: r.Sorted()}, "", "  ")
+	if err != nil {
+		return errors.Errorf("生成转表报告失败 json.Marshal: %v", err)
+	}
+
+	if err := conf_tool.WriteFile(path, data); err != nil {
+		return errors.Errorf("写入转表报告失败 %v %v", path, err)
+	}
+
+	return nil
+}
+
+func Load(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := New()
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, errors.Errorf("解析转表报告失败 %v %v", path, err)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,237 @@
+package ColumnType
+
+import (
+	"Tool-Library/shared/config"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // windows下没有时区数据库
+)
+
+// TimeAsSeconds 时间类型生成int64秒，否则生成 google.protobuf.Timestamp/Duration
+var TimeAsSeconds = false
+
+// TimeZone datetime/date 没有单独指定时区时使用的时区
+var TimeZone = time.Local
+
+func SetTimeZone(name string) error {
+	loc, err := config.ParseLocation(name)
+	if err != nil {
+		return errors.Errorf("设置默认时区失败 %v", err)
+	}
+
+	TimeZone = loc
+	return nil
+}
+
+// ColumnType 表格类型行（第三行）解析后的列类型
+type ColumnType struct {
+	// 原始类型字符串（小写，去空格）
+	Raw string
+
+	// 基础类型 bool int float string datetime date duration fixed fixed64 match_int match_text filter_int filter_string
+	Base string
+
+	// 括号中的参数，保留原始大小写，例如 datetime(Asia/Shanghai)
+	Arg string
+
+	// xxx_list 用逗号分隔的数组
+	List bool
+
+	// xxx? 可空列，生成proto3 optional，空单元格不赋值
+	Nullable bool
+
+	// 类型后面的校验规则，例如 int,>0,notnil 中的 >0,notnil，见 config.ParseValidator
+	Rules string
+}
+
+func Parse(titleType string) ColumnType {
+	typeStr, rules := config.SplitTypeRules(titleType)
+
+	t := ColumnType{
+		Raw:   strings.ToLower(typeStr),
+		Rules: rules,
+	}
+
+	if strings.HasSuffix(typeStr, "?") {
+		typeStr = strings.TrimSpace(strings.TrimSuffix(typeStr, "?"))
+		t.Nullable = true
+	}
+
+	if start, end := strings.Index(typeStr, "("), strings.LastIndex(typeStr, ")"); start > 0 && end > start {
+		t.Arg = strings.TrimSpace(typeStr[start+1 : end])
+		typeStr = typeStr[:start] + typeStr[end+1:]
+	}
+
+	typeStr = strings.ToLower(strings.TrimSpace(typeStr))
+
+	typeArray := strings.Split(typeStr, "_")
+
+	if len(typeArray) == 2 && typeArray[1] == "list" {
+		t.List = true
+		t.Base = typeArray[0]
+
+		//数组没有可空的概念
+		t.Nullable = false
+	} else {
+		t.Base = typeStr
+	}
+
+	return t
+}
+
+// Known 是否是支持的基础类型，不支持的类型会按string处理
+func (t ColumnType) Known() bool {
+	switch t.Base {
+	case "bool", "int", "float", "string", "datetime", "date", "duration", "fixed", "fixed64",
+		"match_int", "match_text", "filter_int", "filter_string":
+		return true
+	}
+
+	return false
+}
+
+// IsTime datetime date duration
+func (t ColumnType) IsTime() bool {
+	return t.Base == "datetime" || t.Base == "date" || t.Base == "duration"
+}
+
+// IsFilter filter_string(a,b) filter_int(a,b) 单元格中的 {match_text} 替换成对应的match_int
+func (t ColumnType) IsFilter() bool {
+	return t.Base == "filter_string" || t.Base == "filter_int"
+}
+
+// MatchNames filter_string(a,b) 引用的match表名，小写
+func (t ColumnType) MatchNames() []string {
+	var names []string
+	for _, v := range strings.Split(t.Arg, ",") {
+		names = append(names, strings.ToLower(strings.TrimSpace(v)))
+	}
+
+	return names
+}
+
+// Validator 类型行中配置的校验规则，没有配置返回nil
+func (t ColumnType) Validator() *config.Validator {
+	if t.Rules == "" {
+		return nil
+	}
+
+	base := "string"
+	switch t.Base {
+	case "int", "match_int", "filter_int":
+		base = "int"
+	case "float", "fixed", "fixed64":
+		base = "float64"
+	case "bool":
+		base = "bool"
+	}
+
+	sep := ""
+	if t.List {
+		sep = ","
+	}
+
+	return config.ParseColumnValidator(t.Rules, base, sep)
+}
+
+// IsFixed fixed(1000) 生成int32，fixed64(1000) 生成int64
+func (t ColumnType) IsFixed() bool {
+	return t.Base == "fixed" || t.Base == "fixed64"
+}
+
+// FixedScale 定点数的放大倍数，不配置默认1000
+func (t ColumnType) FixedScale() (int64, error) {
+	if t.Arg == "" {
+		return 1000, nil
+	}
+
+	scale, err := strconv.ParseInt(t.Arg, 10, 64)
+	if err != nil || scale <= 0 {
+		return 0, errors.Errorf("定点数放大倍数配置错误 %s", t.Raw)
+	}
+
+	return scale, nil
+}
+
+// ScalarProtoType 不带修饰的proto类型
+func (t ColumnType) ScalarProtoType() string {
+	switch t.Base {
+	case "bool":
+		return "bool"
+	case "float":
+		return "float"
+	case "int", "fixed", "match_int", "filter_int":
+		return "int32"
+	case "fixed64":
+		return "int64"
+	case "datetime", "date":
+		if TimeAsSeconds {
+			return "int64"
+		}
+		return "google.protobuf.Timestamp"
+	case "duration":
+		if TimeAsSeconds {
+			return "int64"
+		}
+		return "google.protobuf.Duration"
+	}
+
+	return "string"
+}
+
+// ProtoType proto字段类型，带 repeated/optional 修饰
+func (t ColumnType) ProtoType() string {
+	if t.List {
+		return "repeated " + t.ScalarProtoType()
+	}
+
+	if t.Nullable {
+		return "optional " + t.ScalarProtoType()
+	}
+
+	return t.ScalarProtoType()
+}
+
+// ProtoImport 字段类型需要import的proto文件
+func ProtoImport(protoType string) string {
+	if strings.HasSuffix(protoType, "google.protobuf.Timestamp") {
+		return "google/protobuf/timestamp.proto"
+	}
+
+	if strings.HasSuffix(protoType, "google.protobuf.Duration") {
+		return "google/protobuf/duration.proto"
+	}
+
+	return ""
+}
+
+// Location datetime/date 使用的时区
+func (t ColumnType) Location() (*time.Location, error) {
+	if t.Arg == "" {
+		return TimeZone, nil
+	}
+
+	return config.ParseLocation(t.Arg)
+}
+
+// ParseTime 解析 datetime/date，date 只保留日期
+func (t ColumnType) ParseTime(s string, date1904 bool) (time.Time, error) {
+	loc, err := t.Location()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	v, err := config.ParseDateTime(s, date1904, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if t.Base == "date" {
+		v = v.In(loc)
+		v = time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, loc)
+	}
+
+	return v, nil
+}
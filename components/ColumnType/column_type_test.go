@@ -0,0 +1,46 @@
+package ColumnType
+
+import (
+	"testing"
+)
+
+func TestValidatorComparison(t *testing.T) {
+	tests := []struct {
+		typeText string
+		value    string
+		wantErr  bool
+	}{
+		{typeText: "int,>0", value: "3"},
+		{typeText: "int,>0", value: "0", wantErr: true},
+		{typeText: "int,>0", value: "1.5", wantErr: true},
+		{typeText: "int,>=0", value: "0"},
+		{typeText: "int,>=0", value: "-1", wantErr: true},
+		{typeText: "float,>0", value: "1.5"},
+		{typeText: "float,>0", value: "2"},
+		{typeText: "float,>0", value: "0", wantErr: true},
+		{typeText: "float,>0", value: "-1.5", wantErr: true},
+		{typeText: "float,>=0", value: "0"},
+		{typeText: "float,>=0", value: "0.25"},
+		{typeText: "float,>=0", value: "-0.25", wantErr: true},
+		{typeText: "fixed(1000),>0", value: "1.5"},
+		{typeText: "fixed(1000),>0", value: "0", wantErr: true},
+		{typeText: "fixed64(100),>=0", value: "0.01"},
+		{typeText: "fixed64(100),>=0", value: "-0.01", wantErr: true},
+		{typeText: "float,notnil", value: "1.5"},
+		{typeText: "float,notnil", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		v := Parse(tt.typeText).Validator()
+		if v == nil {
+			t.Fatalf("%s: Validator() = nil", tt.typeText)
+		}
+
+		err := v.CheckRow([]string{tt.value}, "test", "col", 6)
+		if tt.wantErr && err == nil {
+			t.Errorf("%s %q: want error", tt.typeText, tt.value)
+		} else if !tt.wantErr && err != nil {
+			t.Errorf("%s %q: %v", tt.typeText, tt.value, err)
+		}
+	}
+}
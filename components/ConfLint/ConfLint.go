@@ -0,0 +1,220 @@
+package ConfLint
+
+import (
+	"Tool-Library/components/BuildReport"
+	"Tool-Library/components/ColumnType"
+	"Tool-Library/components/ProtoIDGen"
+	"Tool-Library/shared/config"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+const starReadLine = 5
+
+// LintDir 检查配置目录（包括子目录）下所有表格的结构，不生成任何文件，错误和警告记录到report
+func LintDir(confPath string, report *BuildReport.Report) error {
+	fss, err := config.WalkDir(confPath)
+	if err != nil {
+		return errors.Errorf("读取配置目录失败 %v %v", confPath, err)
+	}
+
+	var paths []string
+	for _, f := range fss {
+		paths = append(paths, filepath.Join(confPath, f))
+	}
+
+	LintFiles(confPath, paths, report)
+
+	return nil
+}
+
+// LintFiles 检查指定的表格文件（xlsx、xlsm、ods和csv、tsv、json文本表，见 config.IsTableFile），其他文件忽略，pre-commit时只检查改动的文件
+// 配置目录中的表格使用相对confPath的路径，和转表时的消息名一致
+func LintFiles(confPath string, paths []string, report *BuildReport.Report) {
+	wg := &sync.WaitGroup{}
+
+	// key=表格名（不带后缀） value=页签，检查不同表格的消息名是否重复
+	sheetMux := &sync.Mutex{}
+	sheets := map[string][]string{}
+
+	// key=表格名（不带后缀） value=表格文件
+	files := map[string]string{}
+
+	for _, p := range paths {
+		path := p
+		fName := filepath.Base(path)
+		if rel, errRel := filepath.Rel(confPath, path); errRel == nil && !strings.HasPrefix(rel, "..") {
+			fName = filepath.ToSlash(rel)
+		}
+
+		if strings.HasPrefix(filepath.Base(fName), "~$") {
+			if config.IsTableFile(strings.TrimPrefix(filepath.Base(fName), "~$")) {
+				report.Error(fName, "", 0, 0, "Excel打开表格时生成的锁文件，不要提交，关闭表格后删除")
+			}
+			continue
+		}
+
+		if !config.IsTableFile(fName) {
+			continue
+		}
+
+		filenameOnly := strings.TrimSuffix(fName, filepath.Ext(fName))
+
+		if other, exist := files[filenameOnly]; exist {
+			report.Error(fName, "", 0, 0, "和表格%v同名，生成的proto和数据库会互相覆盖，修改其中一个的文件名", other)
+			continue
+		}
+		files[filenameOnly] = fName
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if errRecover := recover(); errRecover != nil {
+					debug.PrintStack()
+					report.Error(fName, "", 0, 0, "检查表格失败 Err:%v", errRecover)
+				}
+			}()
+
+			data, errRead := os.ReadFile(path)
+			if errRead != nil {
+				report.Error(fName, "", 0, 0, "读取表格失败 %v", errRead)
+				return
+			}
+
+			sheetNames, errLint := LintWorkbook(fName, data, report)
+			if errLint != nil {
+				report.Error(fName, "", 0, 0, "%v", errLint)
+				return
+			}
+
+			sheetMux.Lock()
+			sheets[filenameOnly] = sheetNames
+			sheetMux.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	for name, conflictFiles := range ProtoIDGen.MessageNameConflicts(sheets) {
+		for _, file := range conflictFiles {
+			report.Error(files[file], "", 0, 0, "生成的proto消息名%v和其他表格重复：%v", name, strings.Join(conflictFiles, "，"))
+		}
+	}
+}
+
+// LintWorkbook 检查一个表格：list页签、页签行数、标题和类型行，返回list中存在的页签
+// csv、tsv、json文本表只有一个页签
+func LintWorkbook(fName string, data []byte, report *BuildReport.Report) ([]string, error) {
+	table, errOpen := config.OpenTable(fName, data)
+	if errOpen != nil {
+		return nil, errOpen
+	}
+
+	listRows := table.ListRows
+
+	var sheetNames []string
+
+	listMap := map[string]int{}
+
+	for i, sheetRow := range listRows {
+		if len(sheetRow) < 1 || strings.TrimSpace(sheetRow[0]) == "" {
+			continue
+		}
+		sheetName := strings.TrimSpace(sheetRow[0])
+
+		if row, exist := listMap[sheetName]; exist {
+			report.Error(fName, "list", i+1, 1, "页签[%v]重复配置，第一次在第%d行", sheetName, row)
+			continue
+		}
+		listMap[sheetName] = i + 1
+
+		curSheet := table.Sheet(sheetName)
+		if curSheet == nil {
+			report.Error(fName, "list", i+1, 1, "list中配置的页签[%v]不存在", sheetName)
+			continue
+		}
+		sheetNames = append(sheetNames, sheetName)
+
+		rows, errRead := curSheet.Rows()
+		if errRead != nil {
+			report.Error(fName, sheetName, 0, 0, "读取页签失败 %v", errRead)
+			continue
+		}
+
+		lintSheet(fName, sheetName, rows, report)
+	}
+
+	return sheetNames, nil
+}
+
+func lintSheet(fName string, sheetName string, rows [][]string, report *BuildReport.Report) {
+	if len(rows) < starReadLine {
+		report.Error(fName, sheetName, 0, 0, "表格行数不足 行数：%d ，最低行数要求：%d", len(rows), starReadLine)
+		return
+	}
+
+	titleRow := rows[1]
+	typeRow := rows[2]
+
+	// key=标题 value=第一次出现的列
+	titleCols := map[string]int{}
+
+	// key=proto字段名 value=标题
+	memberNames := map[string]string{}
+
+	for j := 0; j < len(titleRow) || j < len(typeRow); j++ {
+		title := config.SheetCell(rows, 1, j)
+		typeText := strings.TrimSpace(config.SheetCell(rows, 2, j))
+
+		if title == "" {
+			if typeText != "" {
+				report.Error(fName, sheetName, 3, j+1, "类型行和标题行长度不一致，列类型[%v]没有标题", typeText)
+			}
+			continue
+		}
+
+		//嵌套消息的列（例如 pos.x）转表时按每一段生成字段名
+		if !ProtoIDGen.NestedMessage || !strings.Contains(title, ".") {
+			memberName := config.Naming.Name(config.OutputProto, title)
+			if other, exist := memberNames[memberName]; exist && other != title {
+				report.Error(fName, sheetName, 2, j+1, "标题[%v]和[%v]转换成proto字段名后重复：%v", title, other, memberName)
+			} else if !exist {
+				memberNames[memberName] = title
+				if memberName != title {
+					report.Warning(fName, sheetName, 2, j+1, "标题[%v]不是合法的proto字段名，会转换成[%v]", title, memberName)
+				}
+			}
+		}
+
+		if typeText == "" {
+			report.Error(fName, sheetName, 3, j+1, "列[%v]类型为空，转表时会跳过这一列", title)
+			continue
+		}
+
+		colType := ColumnType.Parse(typeText)
+		if !colType.Known() {
+			report.Warning(fName, sheetName, 3, j+1, "列[%v]类型[%v]不支持，会按string处理", title, typeText)
+		}
+
+		first, exist := titleCols[title]
+		if !exist {
+			titleCols[title] = j
+			continue
+		}
+
+		// 同名多列会合并成数组，类型不一致时数组只会使用其中一列的类型
+		firstType := ColumnType.Parse(config.SheetCell(rows, 2, first))
+		if firstType.Raw != colType.Raw || colType.List {
+			report.Error(fName, sheetName, 2, j+1, "标题[%v]和%v列重复且类型不一致（%v，%v），同名列会合并成数组",
+				title, config.CellRef(1, first), firstType.Raw, colType.Raw)
+		} else {
+			report.Warning(fName, sheetName, 2, j+1, "标题[%v]和%v列重复，会合并成数组 repeated %v",
+				title, config.CellRef(1, first), colType.ScalarProtoType())
+		}
+	}
+}
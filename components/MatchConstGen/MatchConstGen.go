@@ -0,0 +1,196 @@
+package MatchConstGen
+
+import (
+	"Tool-Library/components/ProtoIDGen"
+	conf_tool "Tool-Library/components/conf-tool"
+	"Tool-Library/shared/config"
+	"fmt"
+	"github.com/pkg/errors"
+	"go/format"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CsEnum C#生成enum，否则生成 static class + const int
+var CsEnum = true
+
+type matchConst struct {
+	CsName string
+	GoName string
+	Value  int64
+}
+
+type matchType struct {
+	// 目录+文件名+页签名，例如 Buff.xlsx 的 Type 页签 -> BuffType，hero/Buff.xlsx -> HeroBuffType
+	Name string
+
+	// 和表格生成的消息在同一个namespace和package中，见 ProtoIDGen.PackagePerWorkbook
+	CsNamespace string
+
+	// 相对生成目录的子目录，和按表格分package时的proto目录一致，不分package时为空
+	Dir       string
+	GoPackage string
+
+	Consts []matchConst
+}
+
+// GenerateMatchConst 读取配置目录下所有match页签，生成C#和Go常量，路径为空不生成
+func GenerateMatchConst(confPath string, csPath string, goPath string, goPackage string) error {
+	if csPath == "" && goPath == "" {
+		return nil
+	}
+
+	fmt.Println("--------开始生成match常量--------")
+
+	matchSheets, err := config.LoadXlsxMatchSheets(confPath)
+	if err != nil {
+		return errors.Errorf("读取match表失败 %v", err)
+	}
+
+	types, err := toMatchTypes(matchSheets, goPackage)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("match页签数量：", len(types))
+
+	for _, t := range types {
+		if csPath != "" {
+			if errWrite := conf_tool.WriteFile(path.Join(csPath, t.Dir, t.Name+".cs"), []byte(genCs(t))); errWrite != nil {
+				return errors.Errorf("写入C#常量失败 %v %v", t.Name, errWrite)
+			}
+		}
+
+		if goPath != "" {
+			goFile := path.Join(goPath, t.Dir, "match_"+strings.ToLower(t.Name)+".go")
+			if errWrite := conf_tool.WriteFile(goFile, []byte(genGo(t))); errWrite != nil {
+				return errors.Errorf("写入Go常量失败 %v %v", t.Name, errWrite)
+			}
+		}
+	}
+
+	fmt.Println("--------match常量生成结束--------")
+
+	return nil
+}
+
+func toMatchTypes(matchSheets []*config.MatchSheet, goPackage string) ([]*matchType, error) {
+	// key=namespace.类型名
+	typeMap := map[string]string{}
+
+	var types []*matchType
+
+	for _, sheet := range matchSheets {
+		filenameOnly := strings.TrimSuffix(filepath.ToSlash(sheet.FileName), filepath.Ext(sheet.FileName))
+
+		t := &matchType{
+			Name:        config.SanitizeIdentifier(strings.TrimPrefix(ProtoIDGen.GetMessageName(filenameOnly, sheet.SheetName), "confpb"), false),
+			CsNamespace: ProtoIDGen.GetCsNamespace(filenameOnly),
+			GoPackage:   goPackage,
+		}
+
+		if goDir, pkg := ProtoIDGen.GetGoPackage(filenameOnly); pkg != "" {
+			t.Dir, t.GoPackage = goDir, pkg
+		}
+
+		typeKey := t.CsNamespace + "." + t.Name
+		if other, exist := typeMap[typeKey]; exist {
+			return nil, errors.Errorf("match常量类型名重复 %v：%v 和 %v/%v", typeKey, other, sheet.FileName, sheet.SheetName)
+		}
+		typeMap[typeKey] = sheet.FileName + "/" + sheet.SheetName
+
+		csNameMap := map[string]string{}
+		goNameMap := map[string]string{}
+
+		for text, intValue := range sheet.MatchMap {
+			value, err := strconv.ParseInt(intValue, 10, 32)
+			if err != nil {
+				return nil, errors.Errorf("%v/%v match_int不是整数 %v：%v", sheet.FileName, sheet.SheetName, text, intValue)
+			}
+
+			c := matchConst{
+				CsName: config.Naming.Name(config.OutputCSharp, text),
+				GoName: t.Name + strings.TrimPrefix(config.Naming.Name(config.OutputGo, text), "_"),
+				Value:  value,
+			}
+
+			if other, exist := csNameMap[c.CsName]; exist {
+				return nil, errors.Errorf("%v/%v match_text转换成常量名后重复 %v：%v 和 %v", sheet.FileName, sheet.SheetName, c.CsName, other, text)
+			}
+			csNameMap[c.CsName] = text
+
+			if other, exist := goNameMap[c.GoName]; exist {
+				return nil, errors.Errorf("%v/%v match_text转换成常量名后重复 %v：%v 和 %v", sheet.FileName, sheet.SheetName, c.GoName, other, text)
+			}
+			goNameMap[c.GoName] = text
+
+			t.Consts = append(t.Consts, c)
+		}
+
+		sort.Slice(t.Consts, func(i, j int) bool {
+			if t.Consts[i].Value != t.Consts[j].Value {
+				return t.Consts[i].Value < t.Consts[j].Value
+			}
+			return t.Consts[i].CsName < t.Consts[j].CsName
+		})
+
+		types = append(types, t)
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].CsNamespace != types[j].CsNamespace {
+			return types[i].CsNamespace < types[j].CsNamespace
+		}
+		return types[i].Name < types[j].Name
+	})
+
+	return types, nil
+}
+
+func genCs(t *matchType) string {
+	sb := strings.Builder{}
+
+	sb.WriteString("// 由match_int/match_text页签自动生成，不要手动修改\n\n")
+	sb.WriteString("namespace " + t.CsNamespace + "\n{\n")
+
+	if CsEnum {
+		sb.WriteString("    public enum " + t.Name + "\n    {\n")
+		for _, c := range t.Consts {
+			sb.WriteString("        " + c.CsName + " = " + strconv.FormatInt(c.Value, 10) + ",\n")
+		}
+	} else {
+		sb.WriteString("    public static class " + t.Name + "\n    {\n")
+		for _, c := range t.Consts {
+			sb.WriteString("        public const int " + c.CsName + " = " + strconv.FormatInt(c.Value, 10) + ";\n")
+		}
+	}
+
+	sb.WriteString("    }\n}\n")
+
+	return sb.String()
+}
+
+func genGo(t *matchType) string {
+	sb := strings.Builder{}
+
+	sb.WriteString("// Code generated by match_int/match_text. DO NOT EDIT.\n\n")
+	sb.WriteString("package " + t.GoPackage + "\n\n")
+	sb.WriteString("type " + t.Name + " int32\n\n")
+	sb.WriteString("const (\n")
+
+	for _, c := range t.Consts {
+		sb.WriteString("\t" + c.GoName + " " + t.Name + " = " + strconv.FormatInt(c.Value, 10) + "\n")
+	}
+
+	sb.WriteString(")\n")
+
+	source, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return sb.String()
+	}
+
+	return string(source)
+}
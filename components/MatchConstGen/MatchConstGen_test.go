@@ -0,0 +1,67 @@
+package MatchConstGen
+
+import (
+	"Tool-Library/components/ProtoIDGen"
+	"Tool-Library/shared/config"
+	"strings"
+	"testing"
+)
+
+func TestToMatchTypes(t *testing.T) {
+	sheets := []*config.MatchSheet{
+		{FileName: "Buff.xlsx", SheetName: "Type", MatchMap: map[string]string{"fire": "1"}},
+		{FileName: "hero/Buff.xlsx", SheetName: "Type", MatchMap: map[string]string{"ice": "2"}},
+	}
+
+	tests := []struct {
+		name               string
+		packagePerWorkbook bool
+		want               []matchType
+	}{
+		{
+			name: "默认namespace",
+			want: []matchType{
+				{Name: "BuffType", CsNamespace: "Conf", GoPackage: "conf"},
+				{Name: "HeroBuffType", CsNamespace: "Conf", GoPackage: "conf"},
+			},
+		},
+		{
+			name:               "按表格分package",
+			packagePerWorkbook: true,
+			want: []matchType{
+				{Name: "BuffType", CsNamespace: "Conf.Buff", Dir: "buff", GoPackage: "buff"},
+				{Name: "HeroBuffType", CsNamespace: "Conf.Hero.Buff", Dir: "hero/buff", GoPackage: "buff"},
+			},
+		},
+	}
+
+	defer func(old bool) { ProtoIDGen.PackagePerWorkbook = old }(ProtoIDGen.PackagePerWorkbook)
+
+	for _, tt := range tests {
+		ProtoIDGen.PackagePerWorkbook = tt.packagePerWorkbook
+
+		types, err := toMatchTypes(sheets, "conf")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if len(types) != len(tt.want) {
+			t.Fatalf("%s: got %d types, want %d", tt.name, len(types), len(tt.want))
+		}
+
+		for i, want := range tt.want {
+			got := types[i]
+			if got.Name != want.Name || got.CsNamespace != want.CsNamespace || got.Dir != want.Dir || got.GoPackage != want.GoPackage {
+				t.Errorf("%s: type %d = %+v, want %+v", tt.name, i, *got, want)
+			}
+
+			if cs := genCs(got); !strings.Contains(cs, "namespace "+want.CsNamespace+"\n") {
+				t.Errorf("%s: genCs namespace\n%s", tt.name, cs)
+			}
+
+			if goCode := genGo(got); !strings.HasPrefix(strings.SplitN(goCode, "package ", 2)[1], want.GoPackage+"\n") {
+				t.Errorf("%s: genGo package\n%s", tt.name, goCode)
+			}
+		}
+	}
+}
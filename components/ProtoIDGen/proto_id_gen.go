@@ -1,11 +1,15 @@
 package ProtoIDGen
 
 import (
+	"Tool-Library/components/filemode"
+	"Tool-Library/shared/config"
 	"fmt"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // ProtoID
@@ -58,7 +62,7 @@ func SaveGen(protoIdGen *ProtoIdGen, idGenNamePath string) error {
 		return errorEncode
 	}
 
-	err := os.WriteFile(idGenNamePath, data, 0777)
+	err := filemode.WriteFileAtomic(idGenNamePath, data, 0777)
 
 	if err != nil {
 		return errors.Errorf("保存ProtoID记录失败，idGenNamePath：%v  Err:%v ", idGenNamePath, err)
@@ -67,20 +71,31 @@ func SaveGen(protoIdGen *ProtoIdGen, idGenNamePath string) error {
 	return nil
 }
 
+// ProtoIdGen 多个表格并发生成proto时共用，加锁访问
 type ProtoIdGen struct {
+	mux   sync.Mutex
 	idMap map[string]int
 }
 
 func (g *ProtoIdGen) encode() ([]byte, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
 	return yaml.Marshal(g.idMap)
 }
 
 func (g *ProtoIdGen) get(key string) (int, bool) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
 	id, ok := g.idMap[key]
 	return id, ok
 }
 
 func (g *ProtoIdGen) getOrCreate(key, newKey string) int {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
 	id, ok := g.idMap[key]
 	if !ok {
 		id = g.newId(newKey)
@@ -128,7 +143,126 @@ func HumpName(in string) string {
 	return strings.Replace(strings.Title(strings.Replace(in, "_", " ", -1)), " ", "", -1)
 }
 
-// 公共调用方法
+// PackagePerWorkbook 每个表格生成单独的proto package（conf.hero_main），C# namespace（Conf.HeroMain）和Go package（hero_main）一致
+// 子目录中的表格目录也是package的一部分，例如 hero/HeroMain.xlsx -> conf.hero.hero_main
+var PackagePerWorkbook = false
+
+// NestedMessage 标题中用点分隔的列生成嵌套消息，例如 pos.x -> Pos pos，drops.0.id -> repeated Drops drops
+// 不开启时按命名规则转换成普通字段，例如 pos_x
+var NestedMessage = false
+
+// 公共调用方法，filenameOnly 是相对配置目录的路径（不带后缀），子目录中的表格消息名带上目录，例如 hero/Skill.xlsx -> confpbHeroSkillData
 func GetMessageName(filenameOnly string, sheetName string) string {
-	return "confpb" + filenameOnly + sheetName
+	parts := strings.Split(filenameOnly, "/")
+
+	prefix := ""
+	for _, dir := range parts[:len(parts)-1] {
+		prefix += HumpName(config.SanitizeIdentifier(dir, true))
+	}
+
+	return "confpb" + prefix + parts[len(parts)-1] + sheetName
+}
+
+// GetProtoPackage 表格对应的proto package
+func GetProtoPackage(filenameOnly string) string {
+	if !PackagePerWorkbook {
+		return "conf"
+	}
+
+	return "conf." + strings.Join(packageSegments(filenameOnly), ".")
+}
+
+// GetFullMessageName 带package的消息名
+func GetFullMessageName(filenameOnly string, sheetName string) string {
+	return GetProtoPackage(filenameOnly) + "." + GetMessageName(filenameOnly, sheetName)
+}
+
+// GetProtoFileName 相对proto目录的文件名，按表格分package时放到package对应的子目录，不同package的同名消息不会互相覆盖
+func GetProtoFileName(filenameOnly string, sheetName string) string {
+	if !PackagePerWorkbook {
+		return GetMessageName(filenameOnly, sheetName) + ".proto"
+	}
+
+	return strings.Join(packageSegments(filenameOnly), "/") + "/" + GetMessageName(filenameOnly, sheetName) + ".proto"
+}
+
+// GetProtoHeader proto文件头部 syntax package option
+func GetProtoHeader(protoPath string, filenameOnly string) string {
+	if !PackagePerWorkbook {
+		return "syntax = \"proto3\";\n\npackage conf;\n\noption go_package=\"" + protoPath + ";conf\";"
+	}
+
+	goDir, goPackage := GetGoPackage(filenameOnly)
+
+	return "syntax = \"proto3\";\n\npackage " + GetProtoPackage(filenameOnly) + ";\n\n" +
+		"option go_package=\"" + protoPath + goDir + ";" + goPackage + "\";\n" +
+		"option csharp_namespace=\"" + GetCsNamespace(filenameOnly) + "\";"
+}
+
+// GetCsNamespace 表格生成的C#类所在的namespace，和protoc按package生成的一致
+func GetCsNamespace(filenameOnly string) string {
+	csNamespace := "Conf"
+	if !PackagePerWorkbook {
+		return csNamespace
+	}
+
+	for _, segment := range packageSegments(filenameOnly) {
+		csNamespace += "." + HumpName(segment)
+	}
+
+	return csNamespace
+}
+
+// GetGoPackage 按表格分package时Go代码相对生成目录的子目录和package名，不分package时返回空
+func GetGoPackage(filenameOnly string) (string, string) {
+	if !PackagePerWorkbook {
+		return "", ""
+	}
+
+	segments := packageSegments(filenameOnly)
+	return strings.Join(segments, "/"), segments[len(segments)-1]
+}
+
+// packageSegments 目录和表格名转换成小写下划线，例如 hero/HeroMain -> [hero hero_main]
+func packageSegments(filenameOnly string) []string {
+	var segments []string
+	for _, part := range strings.Split(filenameOnly, "/") {
+		segments = append(segments, config.SanitizeIdentifier(config.ConvertCase(config.CaseSnake, part), true))
+	}
+
+	return segments
+}
+
+// MessageNameConflicts 检查不同表格生成的消息名是否重复，sheets key=表格名（不带后缀） value=页签名
+// 返回重复的消息 key=完整消息名 value=表格名
+func MessageNameConflicts(sheets map[string][]string) map[string][]string {
+	sources := map[string][]string{}
+
+	for filenameOnly, sheetNames := range sheets {
+		for _, sheetName := range sheetNames {
+			name := GetFullMessageName(filenameOnly, sheetName)
+
+			exist := false
+			for _, v := range sources[name] {
+				if v == filenameOnly {
+					exist = true
+					break
+				}
+			}
+
+			if !exist {
+				sources[name] = append(sources[name], filenameOnly)
+			}
+		}
+	}
+
+	conflicts := map[string][]string{}
+	for name, files := range sources {
+		if len(files) > 1 {
+			sort.Strings(files)
+			conflicts[name] = files
+		}
+	}
+
+	return conflicts
 }
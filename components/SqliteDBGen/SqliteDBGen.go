@@ -1,28 +1,29 @@
 package SqliteDBGen
 
 import (
+	"Tool-Library/components/BuildCache"
+	"Tool-Library/components/BuildReport"
+	"Tool-Library/components/ColumnType"
 	"Tool-Library/components/ProtoIDGen"
 	"Tool-Library/components/VersionTxtGen"
-	conf_tool "Tool-Library/components/conf-tool"
+	"Tool-Library/components/WorkerPool"
+	excel_to_proto "Tool-Library/components/excel-to-proto"
 	"Tool-Library/components/filemode"
 	"Tool-Library/components/md5"
+	"Tool-Library/shared/config"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/desc/protoparse"
 	"github.com/jhump/protoreflect/dynamic"
-	"github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
-	"github.com/tealeg/xlsx"
 	"log"
 	"os"
 	"path/filepath"
-	"runtime/debug"
 	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
 	"time"
 )
 
@@ -32,7 +33,9 @@ var isMarshal = true
 
 var DBVersionName = "DBVersion.json"
 
-func GenerateSqliteDB(confPath string, ProtoPath string, dbGenPathStr string, joinPath string, allDbVersion *[]VersionTxtGen.MsgToDB) error {
+// GenerateSqliteDB 所有表格的错误都记录到report，全部处理完之后再返回
+// 表格和消息描述使用生成proto时的结果，每个表格只解析一次
+func GenerateSqliteDB(tables *config.TableCache, schemas *excel_to_proto.Schemas, dbGenPathStr string, joinPath string, allDbVersion *[]VersionTxtGen.MsgToDB, report *BuildReport.Report) error {
 	errorMkdir := filemode.MkdirAll(dbGenPathStr, 777)
 	if errorMkdir != nil {
 		return errors.Errorf("创建genDBPath目录 Err:%v", dbGenPathStr)
@@ -40,129 +43,94 @@ func GenerateSqliteDB(confPath string, ProtoPath string, dbGenPathStr string, jo
 
 	fmt.Println("--------开始加载DBMd5Json--------")
 
-	DBVersionPath := dbGenPathStr + DBVersionName
-
-	DBVersionJson, errDBVersion := os.ReadFile(DBVersionPath)
-	if errDBVersion != nil && os.IsNotExist(errDBVersion) {
-		//不存在直接创建
-		fmt.Println("对应路径下不存在DBVersion，直接创建,路径：", DBVersionPath)
-		fp, errCreate := os.Create(DBVersionPath) // 如果文件已存在，会将文件清空。
-		if errCreate != nil {
-			return errors.Errorf("创建在对应路径下不存在ProtoVersion失败，Err: %v", errCreate)
-		}
-
-		DBVersionJson, errDBVersion = os.ReadFile(DBVersionPath)
-		if errDBVersion != nil {
-			return errors.Errorf("创建在ProtoID记录后，重新读取失败: %v", errDBVersion)
-		}
-		// defer延迟调用
-		defer fp.Close() //关闭文件，释放资源。
+	DBVersionData, errLoad := LoadDBVersion(dbGenPathStr)
+	if errLoad != nil {
+		//缓存损坏时重新生成所有数据库
+		fmt.Println("DBVersion读取失败，重新生成所有数据库：", errLoad)
+		DBVersionData = map[string]SheetDBVersion{}
 	}
 
-	DBVersionData := map[string]map[string]VersionTxtGen.MsgToDB{}
-
-	json.Unmarshal(DBVersionJson, &DBVersionData)
+	for _, invalid := range ValidateDBVersion(dbGenPathStr, DBVersionData) {
+		fmt.Println("DBVersion记录无效：", invalid)
+	}
 
 	fmt.Println("--------加载DBMd5Json结束--------")
 
 	fmt.Println("\n--------开始生成数据库--------")
 
-	if strings.HasSuffix(confPath, "/") {
-		confPath = confPath[:len(confPath)-1]
-	}
-	dirWithSep := confPath + "/"
+	dirWithSep := tables.Dir()
 
-	if fss, errReadDir := os.ReadDir(dirWithSep); errReadDir != nil {
+	//包括子目录中的表格，fName是相对配置目录的路径
+	if fss, errReadDir := config.WalkDir(dirWithSep); errReadDir != nil {
 		return errors.Errorf("GenerateProto error 生成失败读取文件, %v", errReadDir)
 	} else {
-		wg := &sync.WaitGroup{}
-		var loadErrorRef atomic.Value
-
-		loadMux := &sync.Mutex{}
-
 		fmt.Println("生成数据库对应表数量：",len(fss))
 
-		for _, f := range fss {
-			fName := f.Name()
-
-			path := dirWithSep + fName
-			if filepath.Ext(fName) != ".xlsx" {
-				continue
-			}
-
-			if strings.Contains(fName, "~$") {
-				continue
-			}
+		matchSheets, errMatch := tables.MatchSheets()
+		if errMatch != nil {
+			return errors.Errorf("读取match表失败, %v", errMatch)
+		}
 
-			wg.Add(1)
+		matchTables := config.NewMatchTables(matchSheets)
+		options := cacheOptions(matchTables)
 
-			go func() {
-				defer wg.Done()
-				defer func() {
-					if errRecover := recover(); errRecover != nil {
-						fmt.Println("GenerateProto error 生成失败,Err:", errRecover)
-						debug.PrintStack()
-					}
-				}()
+		//写入数据库之前先检查所有表格的校验规则
+		if errValidate := validateDir(tables, schemas, fss, matchTables, options, DBVersionData, report); errValidate != nil {
+			return errValidate
+		}
 
-				loadMux.Lock()
-				defer loadMux.Unlock()
+		var files []string
+		for _, fName := range fss {
+			if config.IsTableFile(fName) {
+				files = append(files, fName)
+			}
+		}
 
-				data, errRead := os.ReadFile(path)
-				if errRead != nil {
-					loadErrorRef.Store(errors.Errorf("GenerateProto error 读取文件失败,path:%v %v", path, errRead))
-					return
-				}
+		//每个表格的结果按下标保存，全部结束后按文件顺序写入allDbVersion和DBVersionData，并发时DBVersionData只读
+		results := make([]*tableDBResult, len(files))
 
-				excelMd5 := fName + "_" + md5.String(data)
+		stats := BuildCache.NewStats("数据库")
 
-				if _, ok := DBVersionData[excelMd5]; ok && len(DBVersionData[excelMd5]) > 0 {
-					//存在已经生成的版本跳过
-					excelMd5Proto := DBVersionData[excelMd5]
+		errRun := WorkerPool.Run(context.Background(), len(files), func(ctx context.Context, i int) error {
+			fName := files[i]
 
-					fmt.Println("已经生成过对应表的DB,表名:", fName, "写入数量:", len(excelMd5Proto))
+			errGen := WorkerPool.Safe(func() error {
+				result, errTable := generateTableDB(tables, schemas, fName, matchTables, options, dbGenPathStr, joinPath, DBVersionData, stats, report)
+				results[i] = result
+				return errTable
+			})
 
-					for _, db := range excelMd5Proto {
-						*allDbVersion = append(*allDbVersion, db)
-					}
-				} else {
-					fmt.Println("生成对应表的DB,表名:", fName)
+			if errGen != nil && errGen != errReported {
+				report.Error(fName, "", 0, 0, "生成数据库失败:%v", errGen)
+				return errGen
+			}
 
-					isErr:=false
-					if _, isExist := DBVersionData[excelMd5]; isExist {
-						fmt.Println("错误数据:", excelMd5)
-						isErr=true
-					}
+			return nil
+		})
 
-					DBVersionData[excelMd5] = map[string]VersionTxtGen.MsgToDB{}
+		stats.PrintSummary()
 
-					errGen := GenerateTableDB(path, data, ProtoPath, dbGenPathStr, joinPath, allDbVersion, DBVersionData[excelMd5])
+		for _, result := range results {
+			if result == nil {
+				continue
+			}
 
-					if isErr{
-						fmt.Println("错误数据重新写入大小:", len(DBVersionData[excelMd5]), "数据:", DBVersionData[excelMd5])
-					}
+			*allDbVersion = append(*allDbVersion, result.dbVersion...)
 
-					if errGen != nil {
-						loadErrorRef.Store(errors.Errorf("生成数据库失败:%v", errGen))
-						return
-					}
-				}
-			}()
+			for cacheID, version := range result.versionDBMap {
+				DBVersionData[cacheID] = version
+			}
 		}
 
-		wg.Wait()
-
-		if loadError := loadErrorRef.Load(); loadError != nil {
-			return errors.Errorf("多线程生成DB,error, %v", loadError)
+		if errRun != nil {
+			return errors.Wrapf(errRun, "多线程生成DB中断")
 		}
 
-		jsonBytes, errJson := json.Marshal(DBVersionData)
-
-		if errJson != nil {
-			return errors.Errorf("生成DBVersion JSON失败, %v", errJson)
+		if errReport := report.Err(); errReport != nil {
+			return errors.Errorf("多线程生成DB,error, %v", errReport)
 		}
 
-		if errWrite := conf_tool.WriteFile(DBVersionPath, jsonBytes); errWrite != nil {
+		if errWrite := SaveDBVersion(dbGenPathStr, DBVersionData); errWrite != nil {
 			return errors.Errorf("DBVersion 写入JSON失败, %v", errWrite)
 		}
 	}
@@ -172,127 +140,168 @@ func GenerateSqliteDB(confPath string, ProtoPath string, dbGenPathStr string, jo
 	return nil
 }
 
-func GenerateTableDB(path string, data []byte, ProtoPath string, dbGenPathStr string, joinPath string, allDbVersion *[]VersionTxtGen.MsgToDB, versionDBMap map[string]VersionTxtGen.MsgToDB) error {
+// errReported 错误已经记录到report中，调用方不需要重复记录
+var errReported = errors.New("错误已经记录到转表报告中")
+
+// tableDBResult 一个表格生成的数据库，versionDBMap中是生成成功的页签，更新DBVersion缓存
+type tableDBResult struct {
+	dbVersion    []VersionTxtGen.MsgToDB
+	versionDBMap map[string]SheetDBVersion
+}
+
+
+// SheetCacheID DBVersion.json中页签的key，表格路径#页签名
+func SheetCacheID(fName string, sheetName string) string {
+	return fName + ProtoIDGen.KeySep + sheetName
+}
+
+// generateTableDB 生成一个表格的数据库，有错误时返回的结果中也有已经生成的页签
+func generateTableDB(tables *config.TableCache, schemas *excel_to_proto.Schemas, fName string, matchTables config.MatchTables, options string, dbGenPathStr string, joinPath string, DBVersionData map[string]SheetDBVersion, stats *BuildCache.Stats, report *BuildReport.Report) (*tableDBResult, error) {
+	//生成数据库是最后一步，之后不再使用这个表格
+	defer tables.Release(fName)
 
-	file, errOpenBinary := xlsx.OpenBinary(data)
-	if errOpenBinary != nil {
-		return errors.Wrapf(errOpenBinary, "解析表格数据失败 OpenBinary 表名：%s", path)
+	result := &tableDBResult{versionDBMap: map[string]SheetDBVersion{}}
+
+	errGen := GenerateTableDB(tables, schemas, fName, matchTables, options, dbGenPathStr, joinPath, &result.dbVersion, DBVersionData, result.versionDBMap, stats, report)
+
+	return result, errGen
+}
+
+// GenerateTableDB 单元格和页签的错误记录到report后继续检查，有错误的页签不写数据库，最后返回errReported
+// 页签的缓存key和DBVersionData中的记录一致并且数据库文件存在时不重新生成，生成成功的页签写入versionDBMap
+func GenerateTableDB(tables *config.TableCache, schemas *excel_to_proto.Schemas, fName string, matchTables config.MatchTables, options string, dbGenPathStr string, joinPath string, allDbVersion *[]VersionTxtGen.MsgToDB, DBVersionData map[string]SheetDBVersion, versionDBMap map[string]SheetDBVersion, stats *BuildCache.Stats, report *BuildReport.Report) error {
+	path := tables.Dir() + fName
+
+	//xlsx按list页签读取，csv、tsv、json只有一个页签
+	table, errOpen := tables.Table(fName)
+	if errOpen != nil {
+		report.Error(fName, "", 0, 0, "读取表格失败 表名：%s %v", path, errOpen)
+		return errReported
 	}
 
-	//获取文件名带后缀
-	filenameWithSuffix := filepath.Base(path)
+	//获取文件名带后缀，子目录中的表格带目录，例如 hero/Hero.xlsx
+	filenameWithSuffix := fName
 	//获取文件后缀
-	fileSuffix := filepath.Ext(path)
+	fileSuffix := filepath.Ext(fName)
 	//获取文件名
 	filenameOnly := strings.TrimSuffix(filenameWithSuffix, fileSuffix)
 
-	// 找到需要处理sheet（读取sheet(list)）
-	ListSheet := file.Sheet["list"]
-	if ListSheet == nil {
-		return errors.Errorf("表格数据中没有找到list页签 表名：%s", path)
-	}
+	tableOptions := tableCacheOptions(options, table)
+
+	listRows := table.ListRows
 
-	for i := 0; i < len(ListSheet.Rows); i++ {
+	//有错误的页签不写数据库，继续检查后面的页签
+	failed := false
 
-		sheetRow := ListSheet.Rows[i]
+	for i := 0; i < len(listRows); i++ {
 
-		if len(sheetRow.Cells) < 1 {
+		sheetRow := listRows[i]
+
+		if len(sheetRow) < 1 {
 			continue
 		}
-		sheetName := sheetRow.Cells[0].String()
+		sheetName := sheetRow[0]
 
-		curSheet := file.Sheet[strings.TrimSpace(sheetName)]
+		curSheet := table.Sheet(sheetName)
 
 		if curSheet == nil {
-			return errors.Errorf("找不到sheet sheetName  %s in %s", sheetName, path)
+			report.Error(filenameWithSuffix, sheetName, 0, 0, "找不到sheet sheetName  %s in %s", sheetName, path)
+			failed = true
+			continue
 		}
 
-		dbName := GetDBTableName(filenameOnly, sheetName, md5.String(data), strconv.Itoa(len(data)))
-
-		_, errIsExist := os.Stat(dbGenPathStr + dbName)
-
-		if !os.IsNotExist(errIsExist) {
-
-			newDBInfo := VersionTxtGen.MsgToDB{
-				MsgName:   ProtoIDGen.GetMessageName(filenameOnly, sheetName),
-				FileName:  joinPath+dbName,
-				TableName: filenameOnly,
-				SheetName: sheetName,
-			}
+		rows, errRead := curSheet.Rows()
+		if errRead != nil {
+			report.Error(filenameWithSuffix, sheetName, 0, 0, "读取表格数据失败 表名：%s %v", path, errRead)
+			failed = true
+			continue
+		}
 
-			*allDbVersion = append(*allDbVersion, newDBInfo)
+		if len(rows) < starReadLine {
+			report.Error(filenameWithSuffix, sheetName, 0, 0, "表格行数不足跳过 表名：%v 页签名称：%v 行数：%d ，最低行数要求：%d", path, sheetName, len(rows), starReadLine)
+			failed = true
+			continue
+		}
 
-			versionDBMap[dbName] = newDBInfo
+		titleRow := rows[1]
+		typeRow := rows[2]
 
+		if len(typeRow) != len(titleRow) {
+			report.Error(filenameWithSuffix, sheetName, 0, 0, "表格数据类型和标题数量不一致 表名：%s", path)
+			failed = true
 			continue
 		}
 
 		messageName := ProtoIDGen.GetMessageName(filenameOnly, sheetName)
 
-		Parser := protoparse.Parser{}
-		//加载并解析 proto文件,得到一组 FileDescriptor
-		desCs, err := Parser.ParseFiles(ProtoPath + messageName + ".proto")
-		if err != nil {
-			return errors.Errorf("GenerateSqliteDB error 生成失败解析proto：%v, %v", ProtoPath+messageName+".proto", err)
+		//使用生成proto时在内存中解析的消息描述
+		msgDesc, errDesc := schemas.Message(fName, sheetName, rows)
+		if errDesc != nil {
+			report.Error(filenameWithSuffix, sheetName, 0, 0, "GenerateSqliteDB error 生成消息描述失败：%v, %v", messageName, errDesc)
+			failed = true
+			continue
 		}
 
-		fileDescriptor := desCs[0]
-
-		var msgDesc *desc.MessageDescriptor
-
-		for _, v := range fileDescriptor.GetMessageTypes() {
-			if messageName == v.GetName() {
-				msgDesc = v
-			}
+		key, errKey := sheetCacheKey(schemas, fName, sheetName, rows, tableOptions)
+		if errKey != nil {
+			report.Error(filenameWithSuffix, sheetName, 0, 0, "GenerateSqliteDB error 生成缓存key失败：%v, %v", messageName, errKey)
+			failed = true
+			continue
 		}
 
-		if msgDesc == nil {
-			return errors.Errorf("Proto数据中没有找到messageName 消息名称名称：%v", messageName)
-		}
+		//数据库文件名带缓存key，内容、结构或选项变化时生成新的文件，客户端按文件名判断是否下载
+		dbName := GetDBTableName(filenameOnly, sheetName, key.String(), "")
 
-		driverConns := []*sqlite3.SQLiteConn{}
+		newDBInfo := VersionTxtGen.MsgToDB{
+			MsgName:    GetMessageName(filenameOnly, sheetName),
+			Package:    versionPackage(filenameOnly),
+			FileName:   joinPath + dbName,
+			TableName:  filepath.Base(filenameOnly),
+			SheetName:  sheetName,
+			Path:       fName,
+			SchemaHash: key.Schema,
+		}
 
-		curBackupDriverName := "sqlite3_backup_" + filenameOnly + "_" + sheetName
+		cacheID := SheetCacheID(fName, sheetName)
 
-		sql.Register(curBackupDriverName, &sqlite3.SQLiteDriver{
-			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
-				driverConns = append(driverConns, conn)
-				return nil
-			},
-		})
+		reason := key.Reason(DBVersionData[cacheID].Key)
+		if _, errStat := os.Stat(dbGenPathStr + dbName); reason == "" && errStat != nil {
+			reason = "数据库文件不存在"
+		}
 
-		srcDB, errCreateSrcDB := sql.Open(curBackupDriverName, ":memory:")
+		if reason == "" {
+			stats.Hit(filenameWithSuffix, sheetName)
 
-		if errCreateSrcDB != nil {
-			return errors.Errorf("数据库开启失败 err %v", errCreateSrcDB)
-		}
+			//文件没有变化，沿用缓存记录中的校验信息
+			cached := DBVersionData[cacheID].DB
+			newDBInfo.Sha256, newDBInfo.Size, newDBInfo.Rows = cached.Sha256, cached.Size, cached.Rows
 
-		errPing := srcDB.Ping()
-		if errPing != nil {
-			return errors.Errorf("Failed to connect to the source database:%v", errPing)
+			*allDbVersion = append(*allDbVersion, newDBInfo)
+			versionDBMap[cacheID] = SheetDBVersion{Key: key, DB: newDBInfo, Md5: DBVersionData[cacheID].Md5}
+			continue
 		}
 
-		titleRow := curSheet.Rows[1]
-		defaultRow := curSheet.Rows[4]
-		typeRow := curSheet.Rows[2]
+		stats.Miss(filenameWithSuffix, sheetName, reason)
 
-		if len(typeRow.Cells) != len(titleRow.Cells) {
-			return errors.Errorf("表格数据类型和标题数量不一致 表名：%s", path)
+		writer, errWriter := newDBWriter(dbGenPathStr + dbName)
+		if errWriter != nil {
+			return errors.Errorf("数据库存盘失败 err %v path:%v sheetName:%v", errWriter, path, sheetName)
 		}
 
-		for j := starReadLine; j < len(curSheet.Rows); j++ {
+		sheetFailed := false
+
+		for j := starReadLine; j < len(rows); j++ {
 			msg := dynamic.NewMessage(msgDesc)
 
 			var keyStr string
 
-			curRow := curSheet.Rows[j]
-
 			isExistKey := false
 
-			for k := 0; k < len(titleRow.Cells); k++ {
+			for k := 0; k < len(titleRow); k++ {
 
-				title := titleRow.Cells[k].String()
-				strType := strings.ToLower(typeRow.Cells[k].String())
+				title := titleRow[k]
+				strType := strings.ToLower(typeRow[k])
+				colType := ColumnType.Parse(typeRow[k])
 
 				if title == "" {
 					continue
@@ -302,161 +311,222 @@ func GenerateTableDB(path string, data []byte, ProtoPath string, dbGenPathStr st
 					isExistKey = true
 				}
 
-				cellStr := ""
+				cellStr := getCellStr(rows, curSheet, j, k, colType)
 
-				if k < len(curRow.Cells) {
-					cellStr = curRow.Cells[k].String()
+				if colType.IsFilter() {
+					// {haha}_1_{hehe}_2
+					newValue, errMatch := config.ReplaceMatchString(cellStr, matchTables, colType.MatchNames())
+					if errMatch != nil {
+						report.Error(filenameWithSuffix, sheetName, j+1, k+1, "%v", errors.Wrapf(errMatch, "%s/%s filter_string替换失败 %d行-%d列 %v, matchNames: %v", filenameWithSuffix, sheetName, j+1, k+1, cellStr, colType.MatchNames()))
+						sheetFailed = true
+						continue
+					}
+					cellStr = newValue
 				}
 
-				for _, fieldDesc := range msgDesc.GetFields() {
-					fieldName := fieldDesc.GetName()
+				//proto字段名按命名规则从标题转换
+				memberName := config.Naming.Name(config.OutputProto, title)
+
+				//嵌套消息的列写入对应的子消息，例如 pos.x、drops.0.id
+				fieldMsg := msg
+				if ProtoIDGen.NestedMessage && strings.Contains(title, ".") {
+					//没有值的单元格不创建子消息
+					if strings.TrimSpace(cellStr) == "" && (colType.Nullable || strings.TrimSpace(config.SheetCell(rows, 4, k)) == "") {
+						continue
+					}
+
+					subMsg, leaf, errNested := nestedField(msg, title)
+					if errNested != nil {
+						report.Error(filenameWithSuffix, sheetName, j+1, k+1, "%v", errNested)
+						sheetFailed = true
+						continue
+					}
 
-					if strings.ToLower(fieldName) == strings.ToLower(title) {
+					fieldMsg, memberName = subMsg, config.Naming.Name(config.OutputProto, leaf)
+				}
 
-						if strings.TrimSpace(cellStr) == "" {
-							//为空之后直接去拿默认值,区别Constant 判定是存在key值得表
+				//单元格错误记录到report，继续检查其他单元格
+				errCell := func() error {
+					for _, fieldDesc := range fieldMsg.GetMessageDescriptor().GetFields() {
+						fieldName := fieldDesc.GetName()
 
-							if k < len(defaultRow.Cells) && strings.TrimSpace(defaultRow.Cells[k].String()) != "" {
-								cellStr = defaultRow.Cells[k].String()
-							} else {
+						if strings.ToLower(fieldName) == strings.ToLower(memberName) {
+
+							if strings.TrimSpace(cellStr) == "" {
+								//可空列不赋值，客户端通过HasX区分未配置
+								if colType.Nullable {
+									continue
+								}
+
+								//为空之后直接去拿默认值,区别Constant 判定是存在key值得表
+
+								if strings.TrimSpace(config.SheetCell(rows, 4, k)) != "" {
+									cellStr = getCellStr(rows, curSheet, 4, k, colType)
+								} else {
+									continue
+								}
+							}
+
+							if strings.HasPrefix(cellStr, "**") {
 								continue
 							}
-						}
 
-						if strings.HasPrefix(cellStr, "**") {
-							continue
-						}
+							if colType.IsTime() {
+								errTime := setTimeField(fieldMsg, fieldDesc, colType, cellStr, table.Date1904())
+
+								if errTime != nil {
+									return errors.Errorf("表名：%v_%v title:%v type: %v 行数:%d,列数：%d 对应时间数据转换失败：%v ERR:%v", filenameOnly, sheetName, title, strType, j+1, k+1, cellStr, errTime)
+								}
+								break
+							}
 
-						if fieldDesc.GetType().String() == "TYPE_INT32" {
+							if colType.IsFixed() {
+								errFixed := setFixedField(fieldMsg, fieldDesc, colType, cellStr)
 
-							if fieldDesc.IsRepeated() {
+								if errFixed != nil {
+									return errors.Errorf("表名：%v_%v title:%v type: %v 行数:%d,列数：%d 对应定点数数据转换失败：%v ERR:%v", filenameOnly, sheetName, title, strType, j+1, k+1, cellStr, errFixed)
+								}
+								break
+							}
 
-								if len(strings.Split(strType, "_")) == 2 && strings.Split(strType, "_")[1] == "list" {
-									valueVec := strings.Split(cellStr, ",")
+							if fieldDesc.GetType().String() == "TYPE_INT32" {
 
-									for _, value := range valueVec {
+								if fieldDesc.IsRepeated() {
 
-										value = strings.TrimSpace(value)
+									if colType.List {
+										valueVec := strings.Split(cellStr, ",")
 
-										if value == "" {
-											continue
-										}
+										for _, value := range valueVec {
+
+											value = strings.TrimSpace(value)
+
+											if value == "" {
+												continue
+											}
 
-										valueInt, err := strconv.ParseInt(value, 10, 32)
+											valueInt, err := strconv.ParseInt(value, 10, 32)
+
+											if err != nil {
+												return errors.Errorf("表名：%v_%v title:%v type: %v 行数:%d,列数：%d 对应INT_LIST数据转换失败：%v 对应装换数值 %v ERR:%v", filenameOnly, sheetName, title, strType, j+1, k+1, cellStr, value, err)
+											}
+
+											fieldMsg.AddRepeatedFieldByName(fieldDesc.GetName(), int32(valueInt))
+										}
+									} else {
+										value, err := strconv.Atoi(cellStr)
 
 										if err != nil {
-											return errors.Errorf("表名：%v_%v title:%v type: %v 行数:%d,列数：%d 对应INT_LIST数据转换失败：%v 对应装换数值 %v ERR:%v", filenameOnly, sheetName, title, strType, j+1, k+1, cellStr, value, err)
+											return errors.Errorf("表名：%v_%v 行数:%d,列数：%d 类型：%v 对应INT数据转换失败：%v ERR:%v", filenameOnly, sheetName, j+1, k+1, strType, cellStr, err)
 										}
 
-										msg.AddRepeatedFieldByName(fieldDesc.GetName(), int32(valueInt))
+										fieldMsg.AddRepeatedFieldByName(fieldDesc.GetName(), int32(value))
 									}
 								} else {
+
 									value, err := strconv.Atoi(cellStr)
 
 									if err != nil {
-										return errors.Errorf("表名：%v_%v 行数:%d,列数：%d 类型：%v 对应INT数据转换失败：%v ERR:%v", filenameOnly, sheetName, j+1, k+1, strType, cellStr, err)
+										return errors.Errorf("表名：%v_%v 行数:%d,列数：%d 对应INT数据转换失败：%v ERR:%v", filenameOnly, sheetName, j+1, k+1, cellStr, err)
 									}
 
-									msg.AddRepeatedFieldByName(fieldDesc.GetName(), int32(value))
+									fieldMsg.SetFieldByName(fieldDesc.GetName(), int32(value))
 								}
-							} else {
-
-								value, err := strconv.Atoi(cellStr)
 
-								if err != nil {
-									return errors.Errorf("表名：%v_%v 行数:%d,列数：%d 对应INT数据转换失败：%v ERR:%v", filenameOnly, sheetName, j+1, k+1, cellStr, err)
+								if strings.ToLower(title) == "id" {
+									keyStr = cellStr
 								}
-
-								msg.SetFieldByName(fieldDesc.GetName(), int32(value))
-							}
-
-							if strings.ToLower(title) == "id" {
-								keyStr = cellStr
 							}
-						}
 
-						if fieldDesc.GetType().String() == "TYPE_STRING" {
+							if fieldDesc.GetType().String() == "TYPE_STRING" {
 
-							if fieldDesc.IsRepeated() {
-								if len(strings.Split(strType, "_")) == 2 && strings.Split(strType, "_")[1] == "list" {
-									valueVec := strings.Split(cellStr, ",")
-									for _, value := range valueVec {
-										msg.AddRepeatedFieldByName(fieldDesc.GetName(), value)
+								if fieldDesc.IsRepeated() {
+									if colType.List {
+										valueVec := strings.Split(cellStr, ",")
+										for _, value := range valueVec {
+											fieldMsg.AddRepeatedFieldByName(fieldDesc.GetName(), value)
+										}
+									} else {
+										fieldMsg.AddRepeatedFieldByName(fieldDesc.GetName(), cellStr)
 									}
 								} else {
-									msg.AddRepeatedFieldByName(fieldDesc.GetName(), cellStr)
+									fieldMsg.SetFieldByName(fieldDesc.GetName(), cellStr)
 								}
-							} else {
-								msg.SetFieldByName(fieldDesc.GetName(), cellStr)
-							}
 
-							if strings.ToLower(title) == "key" || strings.ToLower(title) == "id" {
-								keyStr = cellStr
+								if strings.ToLower(title) == "key" || strings.ToLower(title) == "id" {
+									keyStr = cellStr
+								}
 							}
-						}
 
-						if fieldDesc.GetType().String() == "TYPE_BOOL" {
+							if fieldDesc.GetType().String() == "TYPE_BOOL" {
 
-							value, err := strconv.ParseBool(cellStr)
+								value, err := strconv.ParseBool(cellStr)
 
-							if err != nil {
-								return errors.Errorf("行数:%d,列数：%d 对应BOOL数据转换失败：%v ERR:%v", j, k, cellStr, err)
-							}
+								if err != nil {
+									return errors.Errorf("行数:%d,列数：%d 对应BOOL数据转换失败：%v ERR:%v", j, k, cellStr, err)
+								}
 
-							if fieldDesc.IsRepeated() {
-								//布尔不支持重复
-							} else {
-								msg.SetFieldByName(fieldDesc.GetName(), value)
+								if fieldDesc.IsRepeated() {
+									//布尔不支持重复
+								} else {
+									fieldMsg.SetFieldByName(fieldDesc.GetName(), value)
+								}
 							}
-						}
 
-						if fieldDesc.GetType().String() == "TYPE_FLOAT" {
-							if fieldDesc.IsRepeated() {
+							if fieldDesc.GetType().String() == "TYPE_FLOAT" {
+								if fieldDesc.IsRepeated() {
 
-								if len(strings.Split(strType, "_")) == 2 && strings.Split(strType, "_")[1] == "list" {
-									valueVec := strings.Split(cellStr, ",")
-									for _, value := range valueVec {
-										value = strings.TrimSpace(value)
+									if colType.List {
+										valueVec := strings.Split(cellStr, ",")
+										for _, value := range valueVec {
+											value = strings.TrimSpace(value)
 
-										if value == "" {
-											continue
-										}
+											if value == "" {
+												continue
+											}
+
+											valueFloat, err := strconv.ParseFloat(value, 32)
 
-										valueFloat, err := strconv.ParseFloat(value, 32)
+											if err != nil {
+												return errors.Errorf("表名：%v_%v 行数:%d,列数：%d 对应FLOAT数据转换失败：%v ERR:%v", filenameOnly, sheetName, j+1, k+1, cellStr, err)
+											}
+
+											fieldMsg.AddRepeatedFieldByName(fieldDesc.GetName(), float32(valueFloat))
+										}
+									} else {
+										value, err := strconv.ParseFloat(cellStr, 32)
 
 										if err != nil {
 											return errors.Errorf("表名：%v_%v 行数:%d,列数：%d 对应FLOAT数据转换失败：%v ERR:%v", filenameOnly, sheetName, j+1, k+1, cellStr, err)
 										}
 
-										msg.AddRepeatedFieldByName(fieldDesc.GetName(), float32(valueFloat))
+										fieldMsg.AddRepeatedFieldByName(fieldDesc.GetName(), float32(value))
 									}
 								} else {
-									value, err := strconv.ParseFloat(cellStr, 32)
+									cellStr = strings.TrimSpace(cellStr)
 
-									if err != nil {
-										return errors.Errorf("表名：%v_%v 行数:%d,列数：%d 对应FLOAT数据转换失败：%v ERR:%v", filenameOnly, sheetName, j+1, k+1, cellStr, err)
+									if cellStr == "" {
+										continue
 									}
 
-									msg.AddRepeatedFieldByName(fieldDesc.GetName(), float32(value))
-								}
-							} else {
-								cellStr = strings.TrimSpace(cellStr)
-
-								if cellStr == "" {
-									continue
-								}
+									value, err := strconv.ParseFloat(cellStr, 32)
 
-								value, err := strconv.ParseFloat(cellStr, 32)
+									if err != nil {
+										return errors.Errorf("表格数据中FLOAT字段类型错误 %v", err)
+									}
 
-								if err != nil {
-									return errors.Errorf("表格数据中FLOAT字段类型错误 %v", err)
+									fieldMsg.SetFieldByName(fieldDesc.GetName(), float32(value))
 								}
-
-								msg.SetFieldByName(fieldDesc.GetName(), float32(value))
 							}
+							break
 						}
-						break
 					}
+
+					return nil
+				}()
+
+				if errCell != nil {
+					report.Error(filenameWithSuffix, sheetName, j+1, k+1, "%v", errCell)
+					sheetFailed = true
 				}
 			}
 
@@ -474,198 +544,266 @@ func GenerateTableDB(path string, data []byte, ProtoPath string, dbGenPathStr st
 				}
 			}
 
-			errSaveDB := saveToMemoryDB(srcDB, keyStr, msg)
+			if sheetFailed {
+				continue
+			}
+
+			errSaveDB := writer.insert(keyStr, msg)
 
 			if errSaveDB != nil {
+				writer.abort()
 				return errors.Errorf("数据库存盘失败 err %v path:%v sheetName:%v", errSaveDB, path, sheetName)
 			}
 		}
 
-		destDb, errCreateDest := sql.Open(curBackupDriverName, dbGenPathStr+dbName)
-
-		if errCreateDest != nil {
-			return errors.Errorf("数据库存盘失败，sql.Open err %v", errCreateDest)
+		if sheetFailed {
+			writer.abort()
+			failed = true
+			continue
 		}
 
-		errPingDest := destDb.Ping()
-		if errPingDest != nil {
-			return errors.Errorf("Failed to connect to the destination database:%v", errPingDest)
-		}
+		newDBInfo.Rows = len(writer.rows)
 
-		if errSaveToDB := saveToDB(driverConns, destDb, srcDB, filenameOnly, sheetName); errSaveToDB != nil {
-			return errors.Errorf("数据库存盘失败 err %v", errSaveToDB)
+		if errCommit := writer.commit(); errCommit != nil {
+			return errors.Errorf("数据库存盘失败 err %v", errCommit)
 		}
 
-		//获取所有消息名字
-		newDBInfo := VersionTxtGen.MsgToDB{
-			MsgName:   GetMessageName(filenameOnly, sheetName),
-			FileName:  joinPath + dbName,
-			TableName: filenameOnly,
-			SheetName: sheetName,
+		dbMd5, errHash := hashDBFile(dbGenPathStr+dbName, &newDBInfo)
+		if errHash != nil {
+			return errors.Errorf("读取生成的数据库失败 err %v", errHash)
 		}
 
 		*allDbVersion = append(*allDbVersion, newDBInfo)
 
-		versionDBMap[dbName] = newDBInfo
+		versionDBMap[cacheID] = SheetDBVersion{Key: key, DB: newDBInfo, Md5: dbMd5}
+	}
+
+	if failed {
+		return errReported
 	}
 
 	return nil
 }
 
-func saveToMemoryDB(srcDB *sql.DB, keyStr string, m *dynamic.Message) error {
+// validateDir 检查需要重新生成的表格，所有不通过的单元格都记录到report，有错误时不写数据库
+func validateDir(tables *config.TableCache, schemas *excel_to_proto.Schemas, fss []string, matchTables config.MatchTables, options string, DBVersionData map[string]SheetDBVersion, report *BuildReport.Report) error {
+	errCount := report.ErrorCount()
 
-	if srcDB == nil {
-		return errors.Errorf("数据库未开启")
-	}
+	for _, fName := range fss {
 
-	createTableStr := "CREATE TABLE IF NOT EXISTS data  (id string PRIMARY KEY,data byte[]);"
+		if !config.IsTableFile(fName) {
+			continue
+		}
 
-	_, errCreate := srcDB.Exec(createTableStr)
+		table, errOpen := tables.Table(fName)
+		if errOpen != nil {
+			//GenerateTableDB 中会报错
+			continue
+		}
 
-	if errCreate != nil {
-		return errors.Errorf("内存数据库创建表失败，err %v", errCreate)
+		if tableCached(schemas, fName, table, options, DBVersionData) {
+			continue
+		}
+
+		if errValidate := ValidateWorkbook(tables.Dir()+fName, fName, table, matchTables, report); errValidate != nil {
+			report.Error(fName, "", 0, 0, "校验表格失败:%v", errValidate)
+		}
+	}
+
+	if n := report.ErrorCount() - errCount; n > 0 {
+		return errors.Errorf("表格校验不通过，共%d处, %v", n, report.Err())
 	}
 
-	var errInsert error
+	return nil
+}
 
-	if isMarshal {
-		dataMsg, errMarshal := m.Marshal()
+// tableCached 表格所有页签的缓存key都没有变化，不需要重新校验，数据库文件是否存在在生成时检查
+func tableCached(schemas *excel_to_proto.Schemas, fName string, table *config.Table, options string, DBVersionData map[string]SheetDBVersion) bool {
+	tableOptions := tableCacheOptions(options, table)
 
-		if errMarshal != nil {
-			return errors.Errorf("Marshal err %v", errMarshal)
+	for _, sheetRow := range table.ListRows {
+		if len(sheetRow) < 1 {
+			continue
 		}
+		sheetName := sheetRow[0]
 
-		_, errInsert = srcDB.Exec("INSERT INTO data (id, data) VALUES (?, ?)", keyStr, dataMsg)
-	} else {
-		_, errInsert = srcDB.Exec("INSERT INTO data (id, data) VALUES (?, ?)", keyStr, m.String())
-	}
+		curSheet := table.Sheet(sheetName)
+		if curSheet == nil {
+			return false
+		}
+
+		rows, errRead := curSheet.Rows()
+		if errRead != nil || len(rows) < starReadLine {
+			return false
+		}
 
-	if errInsert != nil {
-		return errors.Errorf("内存数据库插入keyStr数据失败,id:%s,err %v data:%v", keyStr, errInsert, m.String())
+		key, errKey := sheetCacheKey(schemas, fName, sheetName, rows, tableOptions)
+		if errKey != nil || key.Reason(DBVersionData[SheetCacheID(fName, sheetName)].Key) != "" {
+			return false
+		}
 	}
 
-	return nil
+	return true
 }
 
-func saveToDB(driverConns []*sqlite3.SQLiteConn, destDb *sql.DB, srcDB *sql.DB, tableName string, sheetName string) error {
+// cacheOptions 影响数据库内容的转表选项
+// filter_string的替换结果依赖match表，match表变化时也要重新生成；按表格分package时version.txt的内容不同
+func cacheOptions(matchTables config.MatchTables) string {
+	matchJson, _ := json.Marshal(matchTables)
+
+	return BuildCache.Digest(
+		string(matchJson),
+		ColumnType.TimeZone.String(),
+		strconv.FormatBool(ProtoIDGen.PackagePerWorkbook),
+		strconv.FormatBool(isMarshal),
+		strconv.Itoa(DBPageSize),
+	)
+}
 
-	if srcDB == nil {
-		return errors.Errorf("保存内存数据库")
-	}
+// tableCacheOptions 1904日期系统影响日期单元格的转换结果
+func tableCacheOptions(options string, table *config.Table) string {
+	return BuildCache.Digest(options, strconv.FormatBool(table.Date1904()))
+}
 
-	if len(driverConns) != 2 {
-		return errors.Errorf("Expected 2 driver connections, but found %v.", len(driverConns))
+// sheetCacheKey 页签单元格内容、生成的proto和转表选项一起决定数据库内容
+func sheetCacheKey(schemas *excel_to_proto.Schemas, fName string, sheetName string, rows [][]string, options string) (BuildCache.Key, error) {
+	schema, errSchema := schemas.Digest(fName, sheetName, rows)
+	if errSchema != nil {
+		return BuildCache.Key{}, errSchema
 	}
 
-	//开始进行备份
-	srcDbDriverConn := driverConns[0]
+	return BuildCache.NewKey(BuildCache.ContentDigest(rows), schema, options), nil
+}
 
-	if srcDbDriverConn == nil {
-		return errors.Errorf("The source database driver connection is nil.")
+// versionPackage version.txt中记录的package，默认的package conf不写，兼容旧版本
+func versionPackage(filenameOnly string) string {
+	if !ProtoIDGen.PackagePerWorkbook {
+		return ""
 	}
 
-	destDbDriverConn := driverConns[1]
+	return ProtoIDGen.GetProtoPackage(filenameOnly)
+}
 
-	if destDbDriverConn == nil {
-		return errors.Errorf("The destination database driver connection is nil.")
+// getCellStr 读取单元格，时间格式的duration单元格（例如 1:30:00）转换成秒
+func getCellStr(rows [][]string, sheet config.WorkbookSheet, row, col int, colType ColumnType.ColumnType) string {
+	if colType.Base == "duration" && sheet != nil {
+		if d, ok := sheet.CellDuration(row, col); ok {
+			return strconv.FormatInt(int64(d/time.Second), 10)
+		}
 	}
 
-	// Prepare to perform the backup.
-	backup, err := destDbDriverConn.Backup("main", srcDbDriverConn, "main")
-	if err != nil {
-		return errors.Errorf("Failed to initialize the backup:%v", err)
-	}
+	return config.SheetCell(rows, row, col)
+}
 
-	// Allow the initial page count and remaining values to be retrieved.
-	// According to <https://www.sqlite.org/c3ref/backup_finish.html>, the page count and remaining values are "... only updated by sqlite3_backup_step()."
-	isDone, err := backup.Step(0)
-	if err != nil {
-		return errors.Errorf("Unable to perform an initial 0-page backup step: %v", err)
-	}
-	if isDone {
-		fmt.Println("Backup is unexpectedly done.")
+// setTimeField datetime date duration 写入 google.protobuf.Timestamp/Duration 或者 int64秒
+func setTimeField(msg *dynamic.Message, fieldDesc *desc.FieldDescriptor, colType ColumnType.ColumnType, cellStr string, date1904 bool) error {
+	valueVec := []string{cellStr}
+	if colType.List {
+		valueVec = strings.Split(cellStr, ",")
 	}
 
-	// Check that the page count and remaining values are reasonable.
-	initialPageCount := backup.PageCount()
-	if initialPageCount <= 0 {
-		fmt.Println("Unexpected initial page count value:", initialPageCount, tableName, sheetName)
-		return nil
-	}
-	initialRemaining := backup.Remaining()
-	if initialRemaining <= 0 {
-		return errors.Errorf("Unexpected initial remaining value: %v", initialRemaining)
-	}
-	if initialRemaining != initialPageCount {
-		return errors.Errorf("Initial remaining value differs from the initial page count value; remaining: %v; page count: %v", initialRemaining, initialPageCount)
-	}
+	for _, value := range valueVec {
+		value = strings.TrimSpace(value)
+
+		if value == "" {
+			continue
+		}
 
-	// Perform the backup.
-	if false {
-		var startTime = time.Now().Unix()
+		var seconds int64
+		var nanos int32
 
-		// Test backing-up using a page-by-page approach.
-		var latestRemaining = initialRemaining
-		for {
-			// Perform the backup step.
-			isDone, err = backup.Step(1)
+		if colType.Base == "duration" {
+			d, err := config.ParseCellDuration(value)
 			if err != nil {
-				return errors.Errorf("Failed to perform a backup step:%v", err)
+				return err
 			}
 
-			// The page count should remain unchanged from its initial value.
-			currentPageCount := backup.PageCount()
-			if currentPageCount != initialPageCount {
-				return errors.Errorf("Current page count differs from the initial page count; initial page count: %v; current page count: %v", initialPageCount, currentPageCount)
+			seconds = int64(d / time.Second)
+			nanos = int32(d % time.Second)
+		} else {
+			t, err := colType.ParseTime(value, date1904)
+			if err != nil {
+				return err
 			}
 
-			// There should now be one less page remaining.
-			currentRemaining := backup.Remaining()
-			expectedRemaining := latestRemaining - 1
-			if currentRemaining != expectedRemaining {
-				return errors.Errorf("Unexpected remaining value; expected remaining value: %v; actual remaining value: %v", expectedRemaining, currentRemaining)
-			}
-			latestRemaining = currentRemaining
+			seconds = t.Unix()
+			nanos = int32(t.Nanosecond())
+		}
 
-			if isDone {
-				break
-			}
+		var fieldValue interface{} = seconds
 
-			// Limit the runtime of the backup attempt.
-			if (time.Now().Unix() - startTime) > 150 {
-				return errors.Errorf("Backup is taking longer than expected.")
-			}
-		}
-	} else {
-		// Test the copying of all remaining pages.
-		isDone, err = backup.Step(-1)
-		if err != nil {
-			return errors.Errorf("Failed to perform a backup step:%v", err)
+		if fieldDesc.GetType().String() == "TYPE_MESSAGE" {
+			timeMsg := dynamic.NewMessage(fieldDesc.GetMessageType())
+			timeMsg.SetFieldByName("seconds", seconds)
+			timeMsg.SetFieldByName("nanos", nanos)
+			fieldValue = timeMsg
+		} else if fieldDesc.GetType().String() != "TYPE_INT64" {
+			return errors.Errorf("时间类型对应的proto字段类型错误 %v", fieldDesc.GetType().String())
 		}
-		if !isDone {
-			return errors.Errorf("Backup is unexpectedly not done.")
+
+		if fieldDesc.IsRepeated() {
+			msg.AddRepeatedField(fieldDesc, fieldValue)
+		} else {
+			msg.SetField(fieldDesc, fieldValue)
 		}
 	}
 
-	// Finish the backup.
-	err = backup.Finish()
+	return nil
+}
+
+// setFixedField fixed(scale) 直接从十进制字符串放大成整数，不经过浮点
+func setFixedField(msg *dynamic.Message, fieldDesc *desc.FieldDescriptor, colType ColumnType.ColumnType, cellStr string) error {
+	scale, err := colType.FixedScale()
 	if err != nil {
-		return errors.Errorf("Failed to finish backup:%v", err)
+		return err
+	}
+
+	valueVec := []string{cellStr}
+	if colType.List {
+		valueVec = strings.Split(cellStr, ",")
 	}
 
-	destDb.Close()
-	srcDB.Close()
+	for _, value := range valueVec {
+		value = strings.TrimSpace(value)
+
+		if value == "" {
+			continue
+		}
+
+		var fieldValue interface{}
+		var err error
+
+		switch fieldDesc.GetType().String() {
+		case "TYPE_INT32":
+			fieldValue, err = config.ParseFixed32(value, scale)
+		case "TYPE_INT64":
+			fieldValue, err = config.ParseFixed(value, scale)
+		default:
+			return errors.Errorf("定点数对应的proto字段类型错误 %v", fieldDesc.GetType().String())
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if fieldDesc.IsRepeated() {
+			msg.AddRepeatedField(fieldDesc, fieldValue)
+		} else {
+			msg.SetField(fieldDesc, fieldValue)
+		}
+	}
 
 	return nil
 }
 
+// GetDBTableName 子目录中的表格目录用下划线连接，数据库都在同一个目录下
 func GetDBTableName(filenameOnly string, sheetName string, param1 string, param2 string) string {
 
-	return filenameOnly + "_" + sheetName + "_" + param1 + param2 + ".db"
+	return strings.ReplaceAll(filenameOnly, "/", "_") + "_" + sheetName + "_" + param1 + param2 + ".db"
 }
 
 func GetMessageName(filenameOnly string, sheetName string) string {
-	return "confpb" + filenameOnly + sheetName
+	return ProtoIDGen.GetMessageName(filenameOnly, sheetName)
 }
 
 func GetDBStr(db string, proto string) error {
@@ -0,0 +1,111 @@
+package SqliteDBGen
+
+import (
+	"Tool-Library/components/BuildCache"
+	"Tool-Library/components/VersionTxtGen"
+	conf_tool "Tool-Library/components/conf-tool"
+	"Tool-Library/components/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// SheetDBVersion DBVersion.json中一个页签的缓存记录，key见 SheetCacheID
+type SheetDBVersion struct {
+	Key BuildCache.Key
+	DB  VersionTxtGen.MsgToDB
+
+	// 数据库文件的md5，读取缓存时检查文件是否被修改或者没有写完
+	Md5 string `json:",omitempty"`
+}
+
+// LoadDBVersion 读取DBVersion.json，文件不存在时返回空记录
+// 旧版本按整个表格记录的缓存没有key，直接丢弃，所有页签重新生成
+func LoadDBVersion(dbGenPathStr string) (map[string]SheetDBVersion, error) {
+	DBVersionData := map[string]SheetDBVersion{}
+
+	data, errRead := os.ReadFile(dbGenPathStr + DBVersionName)
+	if os.IsNotExist(errRead) || (errRead == nil && len(data) == 0) {
+		return DBVersionData, nil
+	}
+
+	if errRead != nil {
+		return nil, errors.Errorf("读取DBVersion失败 %v", errRead)
+	}
+
+	if errJson := json.Unmarshal(data, &DBVersionData); errJson != nil {
+		return nil, errors.Errorf("解析DBVersion失败 %v", errJson)
+	}
+
+	for cacheID, version := range DBVersionData {
+		if version.Key.Generator == "" {
+			delete(DBVersionData, cacheID)
+		}
+	}
+
+	return DBVersionData, nil
+}
+
+// ValidateDBVersion 删除数据库文件不存在或者md5不一致的记录，这些页签下次转表时重新生成，返回删除的记录和原因
+func ValidateDBVersion(dbGenPathStr string, DBVersionData map[string]SheetDBVersion) []string {
+	cacheIDs := make([]string, 0, len(DBVersionData))
+	for cacheID := range DBVersionData {
+		cacheIDs = append(cacheIDs, cacheID)
+	}
+	sort.Strings(cacheIDs)
+
+	var invalid []string
+	for _, cacheID := range cacheIDs {
+		version := DBVersionData[cacheID]
+		dbPath := dbGenPathStr + DBFileName(version.DB.FileName)
+
+		reason := ""
+		if dbMd5, errMd5 := md5.PathString(dbPath); os.IsNotExist(errMd5) {
+			reason = "数据库文件不存在"
+		} else if errMd5 != nil {
+			reason = "读取数据库文件失败 " + errMd5.Error()
+		} else if dbMd5 != version.Md5 {
+			reason = "数据库文件md5不一致"
+		}
+
+		if reason != "" {
+			delete(DBVersionData, cacheID)
+			invalid = append(invalid, cacheID+" "+dbPath+"："+reason)
+		}
+	}
+
+	return invalid
+}
+
+// DBFileName 记录中的FileName带joinPath，转成gen目录中的数据库文件名，joinPath在Windows上可能是反斜杠
+func DBFileName(fileName string) string {
+	return filepath.Base(filepath.FromSlash(fileName))
+}
+
+// hashDBFile 读取一次生成的数据库，把sha256和大小写到version.txt的记录中，返回DBVersion.json使用的md5
+func hashDBFile(dbPath string, info *VersionTxtGen.MsgToDB) (string, error) {
+	data, errRead := os.ReadFile(dbPath)
+	if errRead != nil {
+		return "", errRead
+	}
+
+	sum := sha256.Sum256(data)
+	info.Sha256 = hex.EncodeToString(sum[:])
+	info.Size = int64(len(data))
+
+	return md5.String(data), nil
+}
+
+// SaveDBVersion 原子写入DBVersion.json
+func SaveDBVersion(dbGenPathStr string, DBVersionData map[string]SheetDBVersion) error {
+	jsonBytes, errJson := json.Marshal(DBVersionData)
+	if errJson != nil {
+		return errors.Errorf("生成DBVersion JSON失败, %v", errJson)
+	}
+
+	return conf_tool.WriteFile(dbGenPathStr+DBVersionName, jsonBytes)
+}
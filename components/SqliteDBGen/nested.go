@@ -0,0 +1,65 @@
+package SqliteDBGen
+
+import (
+	"Tool-Library/shared/config"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// nestedField 带点的标题找到对应的子消息，没有时创建，返回子消息和最后一段标题
+// 例如 pos.x 返回 pos 和 x，drops.1.id 返回 drops[1] 和 id，前面的下标没有数据时补空消息
+func nestedField(msg *dynamic.Message, title string) (*dynamic.Message, string, error) {
+	segments := strings.Split(title, ".")
+
+	cur := msg
+
+	for i := 0; i < len(segments)-1; i++ {
+		segment := strings.TrimSpace(segments[i])
+
+		fieldDesc := findField(cur.GetMessageDescriptor(), config.Naming.Name(config.OutputProto, segment))
+		if fieldDesc == nil || fieldDesc.GetMessageType() == nil {
+			return nil, "", errors.Errorf("标题[%v]在proto中没有对应的嵌套消息 %v", title, segment)
+		}
+
+		if !fieldDesc.IsRepeated() {
+			if !cur.HasField(fieldDesc) {
+				cur.SetField(fieldDesc, dynamic.NewMessage(fieldDesc.GetMessageType()))
+			}
+
+			cur = cur.GetField(fieldDesc).(*dynamic.Message)
+			continue
+		}
+
+		// repeated消息后面是数组下标
+		i++
+		if i >= len(segments)-1 {
+			return nil, "", errors.Errorf("标题[%v]缺少数组下标", title)
+		}
+
+		index, errIndex := strconv.Atoi(strings.TrimSpace(segments[i]))
+		if errIndex != nil || index < 0 {
+			return nil, "", errors.Errorf("标题[%v]数组下标不对 %v", title, segments[i])
+		}
+
+		for cur.FieldLength(fieldDesc) <= index {
+			cur.AddRepeatedField(fieldDesc, dynamic.NewMessage(fieldDesc.GetMessageType()))
+		}
+
+		cur = cur.GetRepeatedField(fieldDesc, index).(*dynamic.Message)
+	}
+
+	return cur, strings.TrimSpace(segments[len(segments)-1]), nil
+}
+
+func findField(msgDesc *desc.MessageDescriptor, memberName string) *desc.FieldDescriptor {
+	for _, fieldDesc := range msgDesc.GetFields() {
+		if strings.ToLower(fieldDesc.GetName()) == strings.ToLower(memberName) {
+			return fieldDesc
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package SqliteDBGen
+
+import (
+	"Tool-Library/components/BuildReport"
+	"Tool-Library/components/ColumnType"
+	"Tool-Library/shared/config"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// ValidateWorkbook 按类型行中配置的校验规则（例如 int,>0,notnil）检查所有会写入数据库的单元格，不通过的单元格记录到report
+func ValidateWorkbook(path string, fName string, table *config.Table, matchTables config.MatchTables, report *BuildReport.Report) error {
+	filenameWithSuffix := fName
+
+	for _, sheetRow := range table.ListRows {
+		if len(sheetRow) < 1 {
+			continue
+		}
+		sheetName := sheetRow[0]
+
+		curSheet := table.Sheet(sheetName)
+		if curSheet == nil {
+			//GenerateTableDB 中会报错
+			continue
+		}
+
+		rows, errRead := curSheet.Rows()
+		if errRead != nil {
+			return errors.Wrapf(errRead, "读取表格数据失败 表名：%s", path)
+		}
+
+		if len(rows) < starReadLine {
+			continue
+		}
+
+		titleRow := rows[1]
+		typeRow := rows[2]
+
+		keyIndex := -1
+		validators := map[int]*config.Validator{}
+
+		for k, title := range titleRow {
+			if title == "" {
+				continue
+			}
+
+			if keyIndex == -1 && (strings.ToLower(title) == "key" || strings.ToLower(title) == "id") {
+				keyIndex = k
+			}
+
+			if v := ColumnType.Parse(config.SheetCell(rows, 2, k)).Validator(); v != nil {
+				validators[k] = v
+			}
+		}
+
+		if len(validators) == 0 {
+			continue
+		}
+
+		for j := starReadLine; j < len(rows); j++ {
+			// 和 GenerateTableDB 一样，没有key的行不写入数据库，常量表只有第一行
+			if keyIndex == -1 {
+				if j != starReadLine {
+					break
+				}
+			} else if strings.TrimSpace(config.SheetCell(rows, j, keyIndex)) == "" {
+				continue
+			}
+
+			for k := 0; k < len(typeRow); k++ {
+				v := validators[k]
+				if v == nil {
+					continue
+				}
+
+				colType := ColumnType.Parse(typeRow[k])
+				ref := filenameWithSuffix + "!" + curSheet.Name() + "!" + config.CellRef(j, k)
+
+				cellStr := getCellStr(rows, curSheet, j, k, colType)
+
+				if colType.IsFilter() {
+					newValue, errMatch := config.ReplaceMatchString(cellStr, matchTables, colType.MatchNames())
+					if errMatch != nil {
+						report.Error(filenameWithSuffix, curSheet.Name(), j+1, k+1, "%v", errors.Wrapf(errMatch, "%s filter_string替换失败", ref))
+						continue
+					}
+					cellStr = newValue
+				}
+
+				if strings.TrimSpace(cellStr) == "" && !colType.Nullable {
+					cellStr = getCellStr(rows, curSheet, 4, k, colType)
+				}
+
+				if strings.HasPrefix(cellStr, "**") {
+					continue
+				}
+
+				if errCheck := v.CheckRow([]string{cellStr}, ref, titleRow[k], j+1); errCheck != nil {
+					report.Error(filenameWithSuffix, curSheet.Name(), j+1, k+1, "%v", errCheck)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package SqliteDBGen
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jhump/protoreflect/dynamic"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"os"
+	"sort"
+)
+
+// DBPageSize 数据库页大小，WITHOUT ROWID表的一行不超过页大小的1/20时不会溢出到其他页，配置数据一般在400字节以内
+var DBPageSize = 8192
+
+// dbWriterPragmas 数据库只在转表时写入一次，写入失败时删除临时文件重新生成，不需要日志和同步
+// 编码和auto_vacuum固定，不依赖sqlite编译默认值，同样的数据生成同样的文件
+var dbWriterPragmas = []string{
+	"PRAGMA encoding = 'UTF-8'",
+	"PRAGMA auto_vacuum = NONE",
+	"PRAGMA journal_mode = OFF",
+	"PRAGMA synchronous = OFF",
+	"PRAGMA locking_mode = EXCLUSIVE",
+	"PRAGMA temp_store = MEMORY",
+}
+
+// createDataTable 表结构和客户端读取方式保持不变，id按key查询，不需要rowid
+const createDataTable = "CREATE TABLE data (id string PRIMARY KEY, data byte[]) WITHOUT ROWID;"
+
+// dbWriter 一个页签的数据库，先写到临时文件，commit后改成目标文件名
+// 数据库文件存在就认为已经生成过（见 GenerateTableDB），写到一半失败的文件不能使用目标文件名
+// 不注册驱动，可以在conf-http等常驻进程中重复生成同一个页签
+// 数据先缓存，commit时按id排序后写入并VACUUM，同样的表格和工具版本生成的文件完全一致，客户端按md5判断是否下载
+type dbWriter struct {
+	path    string
+	tmpPath string
+
+	db   *sql.DB
+	rows []dbRow
+}
+
+type dbRow struct {
+	id   string
+	data interface{}
+}
+
+func newDBWriter(path string) (*dbWriter, error) {
+	w := &dbWriter{path: path, tmpPath: path + ".tmp"}
+
+	if errRemove := os.Remove(w.tmpPath); errRemove != nil && !os.IsNotExist(errRemove) {
+		return nil, errors.Errorf("删除临时数据库失败 %v", errRemove)
+	}
+
+	db, errOpen := sql.Open("sqlite3", w.tmpPath)
+	if errOpen != nil {
+		return nil, errors.Errorf("数据库开启失败 err %v", errOpen)
+	}
+	w.db = db
+
+	//pragma只对当前连接生效，只使用一个连接
+	db.SetMaxOpenConns(1)
+
+	//page_size 要在建表前设置
+	pragmas := append([]string{fmt.Sprintf("PRAGMA page_size = %d", DBPageSize)}, dbWriterPragmas...)
+	for _, pragma := range pragmas {
+		if _, err := db.Exec(pragma); err != nil {
+			w.abort()
+			return nil, errors.Errorf("设置数据库参数失败 %v err %v", pragma, err)
+		}
+	}
+
+	if _, err := db.Exec(createDataTable); err != nil {
+		w.abort()
+		return nil, errors.Errorf("数据库创建表失败，err %v", err)
+	}
+
+	return w, nil
+}
+
+func (w *dbWriter) insert(keyStr string, m *dynamic.Message) error {
+	if !isMarshal {
+		w.rows = append(w.rows, dbRow{id: keyStr, data: m.String()})
+		return nil
+	}
+
+	//map字段和未知字段按固定顺序序列化，不依赖protoreflect版本
+	dataMsg, errMarshal := m.MarshalDeterministic()
+	if errMarshal != nil {
+		return errors.Errorf("Marshal err %v", errMarshal)
+	}
+
+	w.rows = append(w.rows, dbRow{id: keyStr, data: dataMsg})
+	return nil
+}
+
+// commit 在一个事务中按id顺序写入，VACUUM整理页面后改成目标文件名
+func (w *dbWriter) commit() error {
+	if errWrite := w.write(); errWrite != nil {
+		w.abort()
+		return errWrite
+	}
+
+	errClose := w.db.Close()
+	w.db = nil
+	if errClose != nil {
+		w.abort()
+		return errors.Errorf("数据库关闭失败 %v", errClose)
+	}
+
+	if errRename := os.Rename(w.tmpPath, w.path); errRename != nil {
+		w.abort()
+		return errors.Errorf("数据库改名失败 %v", errRename)
+	}
+
+	return nil
+}
+
+func (w *dbWriter) write() error {
+	sort.SliceStable(w.rows, func(i, j int) bool {
+		return w.rows[i].id < w.rows[j].id
+	})
+
+	tx, errBegin := w.db.Begin()
+	if errBegin != nil {
+		return errors.Errorf("数据库开启事务失败 %v", errBegin)
+	}
+
+	stmt, errPrepare := tx.Prepare("INSERT INTO data (id, data) VALUES (?, ?)")
+	if errPrepare != nil {
+		tx.Rollback()
+		return errors.Errorf("数据库预编译插入语句失败 %v", errPrepare)
+	}
+
+	for _, row := range w.rows {
+		if _, errInsert := stmt.Exec(row.id, row.data); errInsert != nil {
+			stmt.Close()
+			tx.Rollback()
+			return errors.Errorf("数据库插入keyStr数据失败,id:%s,err %v", row.id, errInsert)
+		}
+	}
+
+	stmt.Close()
+
+	if errCommit := tx.Commit(); errCommit != nil {
+		return errors.Errorf("数据库提交事务失败 %v", errCommit)
+	}
+
+	if _, errVacuum := w.db.Exec("VACUUM"); errVacuum != nil {
+		return errors.Errorf("数据库VACUUM失败 %v", errVacuum)
+	}
+
+	return nil
+}
+
+// abort 放弃写入，删除临时文件
+func (w *dbWriter) abort() {
+	if w.db != nil {
+		w.db.Close()
+		w.db = nil
+	}
+
+	w.rows = nil
+
+	os.Remove(w.tmpPath)
+}
@@ -0,0 +1,146 @@
+package VersionDiff
+
+import (
+	"Tool-Library/components/VersionTxtGen"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Entry 一个有变化的数据库，Size是客户端需要下载的大小，删除的数据库是旧文件的大小
+type Entry struct {
+	MsgName   string `json:"msg_name"`
+	Package   string `json:"package,omitempty"`
+	TableName string `json:"table_name"`
+	SheetName string `json:"sheet_name"`
+	FileName  string `json:"file_name"`
+	Size      int64  `json:"size"`
+	Rows      int    `json:"rows"`
+
+	// 修改的数据库原来的文件，客户端下载完新文件后删除
+	OldFileName string `json:"old_file_name,omitempty"`
+	OldSize     int64  `json:"old_size,omitempty"`
+}
+
+// Diff 两个version.txt之间的差异，按消息名排序
+type Diff struct {
+	Added   []Entry `json:"added"`
+	Changed []Entry `json:"changed"`
+	Removed []Entry `json:"removed"`
+
+	// 新增和修改的数据库大小之和
+	DownloadSize int64 `json:"download_size"`
+}
+
+// Load 读取version.txt，旧格式没有的字段为空
+func Load(path string) (*VersionTxtGen.VersionText, error) {
+	data, errRead := os.ReadFile(path)
+	if errRead != nil {
+		return nil, errors.Errorf("读取版本文件失败 %v", errRead)
+	}
+
+	version := &VersionTxtGen.VersionText{}
+	if errJson := json.Unmarshal(data, version); errJson != nil {
+		return nil, errors.Errorf("解析版本文件失败 %v %v", path, errJson)
+	}
+
+	return version, nil
+}
+
+// FillSizes 旧版本生成的version.txt没有数据库大小，按数据库文件补上
+// dir是version.txt所在的打包目录，数据库和它在同一个目录，FileName中的目录是生成时的joinPath，不使用
+// 文件不存在时大小保持0，返回这些数据库的文件名，旧版本的数据库可能已经清理
+func FillSizes(version *VersionTxtGen.VersionText, dir string) ([]string, error) {
+	var missing []string
+	for i := range version.CellList {
+		cell := &version.CellList[i]
+		if cell.Size > 0 {
+			continue
+		}
+
+		info, errStat := os.Stat(filepath.Join(dir, filepath.Base(filepath.FromSlash(cell.FileName))))
+		if os.IsNotExist(errStat) {
+			missing = append(missing, cell.FileName)
+			continue
+		}
+
+		if errStat != nil {
+			return nil, errors.Errorf("读取数据库大小失败 %v", errStat)
+		}
+		cell.Size = info.Size()
+	}
+
+	return missing, nil
+}
+
+// Compare 按package和消息名对应两个版本中的页签，数据库文件名带缓存key，文件名或者sha256不同就需要重新下载
+func Compare(from *VersionTxtGen.VersionText, to *VersionTxtGen.VersionText) *Diff {
+	fromCells := map[string]VersionTxtGen.MsgToDB{}
+	for _, cell := range from.CellList {
+		fromCells[cellKey(cell)] = cell
+	}
+
+	diff := &Diff{Added: []Entry{}, Changed: []Entry{}, Removed: []Entry{}}
+
+	toCells := map[string]struct{}{}
+	for _, cell := range to.CellList {
+		toCells[cellKey(cell)] = struct{}{}
+
+		old, exist := fromCells[cellKey(cell)]
+		if !exist {
+			diff.Added = append(diff.Added, newEntry(cell))
+			diff.DownloadSize += cell.Size
+			continue
+		}
+
+		if old.FileName == cell.FileName && old.Sha256 == cell.Sha256 {
+			continue
+		}
+
+		entry := newEntry(cell)
+		entry.OldFileName = old.FileName
+		entry.OldSize = old.Size
+
+		diff.Changed = append(diff.Changed, entry)
+		diff.DownloadSize += cell.Size
+	}
+
+	for _, cell := range from.CellList {
+		if _, exist := toCells[cellKey(cell)]; !exist {
+			diff.Removed = append(diff.Removed, newEntry(cell))
+		}
+	}
+
+	for _, entries := range [][]Entry{diff.Added, diff.Changed, diff.Removed} {
+		sortEntries(entries)
+	}
+
+	return diff
+}
+
+func cellKey(cell VersionTxtGen.MsgToDB) string {
+	return cell.Package + "." + cell.MsgName
+}
+
+func newEntry(cell VersionTxtGen.MsgToDB) Entry {
+	return Entry{
+		MsgName:   cell.MsgName,
+		Package:   cell.Package,
+		TableName: cell.TableName,
+		SheetName: cell.SheetName,
+		FileName:  cell.FileName,
+		Size:      cell.Size,
+		Rows:      cell.Rows,
+	}
+}
+
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Package != entries[j].Package {
+			return entries[i].Package < entries[j].Package
+		}
+		return entries[i].MsgName < entries[j].MsgName
+	})
+}
@@ -0,0 +1,120 @@
+package VersionDiff
+
+import (
+	"Tool-Library/components/VersionTxtGen"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFillSizes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Hero_Main_a.db"), make([]byte, 100), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧格式没有Size，FileName中的目录是生成时的joinPath
+	version := &VersionTxtGen.VersionText{CellList: []VersionTxtGen.MsgToDB{
+		{MsgName: "HeroMain", FileName: "3/Hero_Main_a.db"},
+		{MsgName: "ItemMain", FileName: "3/Item_Main_a.db"},
+		{MsgName: "SkillMain", FileName: "3/Skill_Main_a.db", Size: 7},
+	}}
+
+	missing, err := FillSizes(version, dir)
+	if err != nil {
+		t.Fatalf("FillSizes error %v", err)
+	}
+
+	if !reflect.DeepEqual(missing, []string{"3/Item_Main_a.db"}) {
+		t.Errorf("missing = %v", missing)
+	}
+
+	var sizes []int64
+	for _, cell := range version.CellList {
+		sizes = append(sizes, cell.Size)
+	}
+	if !reflect.DeepEqual(sizes, []int64{100, 0, 7}) {
+		t.Errorf("sizes = %v, want [100 0 7]", sizes)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// 旧格式：没有sha256和大小，只能按文件名判断
+	oldFormat := &VersionTxtGen.VersionText{CellList: []VersionTxtGen.MsgToDB{
+		{MsgName: "HeroMain", TableName: "Hero", SheetName: "Main", FileName: "3/Hero_Main_a.db"},
+		{MsgName: "ItemMain", TableName: "Item", SheetName: "Main", FileName: "3/Item_Main_a.db"},
+		{MsgName: "DropMain", TableName: "Drop", SheetName: "Main", FileName: "3/Drop_Main_a.db"},
+	}}
+
+	newFormat := &VersionTxtGen.VersionText{CellList: []VersionTxtGen.MsgToDB{
+		{MsgName: "HeroMain", TableName: "Hero", SheetName: "Main", FileName: "4/Hero_Main_a.db", Sha256: "h1", Size: 100, Rows: 3},
+		{MsgName: "ItemMain", TableName: "Item", SheetName: "Main", FileName: "4/Item_Main_b.db", Sha256: "i1", Size: 200, Rows: 5},
+		{MsgName: "BuffMain", Package: "conf.hero", TableName: "Buff", SheetName: "Main", FileName: "4/Buff_Main_a.db", Sha256: "b1", Size: 50, Rows: 1},
+		{MsgName: "BuffMain", TableName: "Buff", SheetName: "Main", FileName: "4/Buff_Main_c.db", Sha256: "b2", Size: 60, Rows: 2},
+	}}
+
+	// 新格式之间：文件名或者sha256不同都要重新下载
+	newer := &VersionTxtGen.VersionText{CellList: []VersionTxtGen.MsgToDB{
+		{MsgName: "HeroMain", TableName: "Hero", SheetName: "Main", FileName: "5/Hero_Main_a.db", Sha256: "h1", Size: 100, Rows: 3},
+		{MsgName: "ItemMain", TableName: "Item", SheetName: "Main", FileName: "4/Item_Main_b.db", Sha256: "i2", Size: 210, Rows: 6},
+		{MsgName: "BuffMain", Package: "conf.hero", TableName: "Buff", SheetName: "Main", FileName: "5/Buff_Main_a.db", Sha256: "b1", Size: 50, Rows: 1},
+	}}
+
+	tests := []struct {
+		name     string
+		from, to *VersionTxtGen.VersionText
+		want     *Diff
+	}{
+		{
+			name: "旧格式到新格式",
+			from: oldFormat,
+			to:   newFormat,
+			want: &Diff{
+				Added: []Entry{
+					{MsgName: "BuffMain", TableName: "Buff", SheetName: "Main", FileName: "4/Buff_Main_c.db", Size: 60, Rows: 2},
+					{MsgName: "BuffMain", Package: "conf.hero", TableName: "Buff", SheetName: "Main", FileName: "4/Buff_Main_a.db", Size: 50, Rows: 1},
+				},
+				Changed: []Entry{
+					{MsgName: "HeroMain", TableName: "Hero", SheetName: "Main", FileName: "4/Hero_Main_a.db", Size: 100, Rows: 3, OldFileName: "3/Hero_Main_a.db"},
+					{MsgName: "ItemMain", TableName: "Item", SheetName: "Main", FileName: "4/Item_Main_b.db", Size: 200, Rows: 5, OldFileName: "3/Item_Main_a.db"},
+				},
+				Removed: []Entry{
+					{MsgName: "DropMain", TableName: "Drop", SheetName: "Main", FileName: "3/Drop_Main_a.db"},
+				},
+				DownloadSize: 410,
+			},
+		},
+		{
+			name: "新格式之间",
+			from: newFormat,
+			to:   newer,
+			want: &Diff{
+				Added: []Entry{},
+				Changed: []Entry{
+					{MsgName: "HeroMain", TableName: "Hero", SheetName: "Main", FileName: "5/Hero_Main_a.db", Size: 100, Rows: 3, OldFileName: "4/Hero_Main_a.db", OldSize: 100},
+					{MsgName: "ItemMain", TableName: "Item", SheetName: "Main", FileName: "4/Item_Main_b.db", Size: 210, Rows: 6, OldFileName: "4/Item_Main_b.db", OldSize: 200},
+					{MsgName: "BuffMain", Package: "conf.hero", TableName: "Buff", SheetName: "Main", FileName: "5/Buff_Main_a.db", Size: 50, Rows: 1, OldFileName: "4/Buff_Main_a.db", OldSize: 50},
+				},
+				Removed: []Entry{
+					{MsgName: "BuffMain", TableName: "Buff", SheetName: "Main", FileName: "4/Buff_Main_c.db", Size: 60, Rows: 2},
+				},
+				DownloadSize: 360,
+			},
+		},
+		{
+			name: "没有变化",
+			from: newer,
+			to:   newer,
+			want: &Diff{Added: []Entry{}, Changed: []Entry{}, Removed: []Entry{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Compare(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare() = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
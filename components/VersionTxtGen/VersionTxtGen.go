@@ -1,53 +1,138 @@
 package VersionTxtGen
 
 import (
-	"encoding/json"
+	"Tool-Library/components/BuildCache"
+	"Tool-Library/components/filemode"
+	"Tool-Library/shared/config"
+	"crypto/ed25519"
 	"fmt"
 	"github.com/pkg/errors"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// MsgToDB version.txt中的一个数据库，新加的字段旧版本客户端解析时会忽略
 type MsgToDB struct {
 	MsgName   string
 	FileName  string
 	TableName string
 	SheetName string
+
+	// 相对配置目录的表格路径，例如 hero/Hero.xlsx
+	Path string `json:",omitempty"`
+
+	// 按表格分package时消息所在的proto package，默认的package conf不写
+	Package string `json:",omitempty"`
+
+	// 数据库文件的sha256（hex）和字节数，客户端下载后校验
+	Sha256 string `json:",omitempty"`
+	Size   int64  `json:",omitempty"`
+
+	// 数据行数
+	Rows int
+
+	// 页签proto内容的md5，字段或者ProtoID变化时变化
+	SchemaHash string `json:",omitempty"`
 }
 
 type VersionText struct {
 	CellList []MsgToDB
+
+	// 生成version.txt的转表工具版本，见 BuildCache.GeneratorVersion
+	Generator string `json:",omitempty"`
+
+	// conf-http的打包版本号
+	PackVersion string `json:",omitempty"`
+
+	// 生成时间 RFC3339，设置了环境变量 SOURCE_DATE_EPOCH 时使用它，否则是最新的表格修改时间，见 BuildTime
+	CreatedAt string `json:",omitempty"`
+
+	// ed25519签名（base64），签名内容是去掉这个字段之后的version.txt，必须是最后一个字段，见 Verify
+	Signature string `json:",omitempty"`
 }
 
-func GenerateVersionFile(dbPath string, allDbVersion []MsgToDB) error {
+// ManifestInfo version.txt中数据库列表之外的信息
+type ManifestInfo struct {
+	PackVersion string
 
-	fmt.Println("--------开始生成版本文件--------")
+	// 零值时不写
+	CreatedAt time.Time
+
+	// 为nil时不签名
+	SignKey ed25519.PrivateKey
+}
+
+// BuildTime version.txt的生成时间，设置了 SOURCE_DATE_EPOCH（秒）时使用它，否则使用配置目录中最新的表格修改时间
+// 同样的输入生成同样的文件，没有表格时返回零值，version.txt不写生成时间
+func BuildTime(confPath string) (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, errors.Errorf("SOURCE_DATE_EPOCH格式错误 %v", epoch)
+		}
+
+		return time.Unix(seconds, 0), nil
+	}
 
-	_, errVersionStat := os.Stat(dbPath + "version.txt")
+	dir := strings.TrimSuffix(confPath, "/") + "/"
 
-	if !os.IsNotExist(errVersionStat) {
-		fmt.Println("存在旧版本文件，删除旧版本文件")
-		os.Remove(dbPath + "version.txt")
-	} else {
-		fmt.Println("不存在旧版本文件，开始生成新版本文件")
+	fss, err := config.WalkDir(dir)
+	if err != nil {
+		return time.Time{}, errors.Errorf("读取配置目录失败 %v", err)
 	}
 
-	newVersion, errNew := os.Create(dbPath + "version.txt")
-	if errNew != nil {
-		return errors.Errorf("创建版本文件失败 %v", errNew)
+	var newest time.Time
+	for _, fName := range fss {
+		if !config.IsTableFile(fName) {
+			continue
+		}
+
+		info, errStat := os.Stat(dir + fName)
+		if errStat != nil {
+			return time.Time{}, errors.Errorf("读取表格修改时间失败 %v", errStat)
+		}
+
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
 	}
-	defer newVersion.Close()
+
+	//version.txt的时间精确到秒
+	return newest.Truncate(time.Second), nil
+}
+
+func GenerateVersionFile(dbPath string, allDbVersion []MsgToDB, info ManifestInfo) error {
+
+	fmt.Println("--------开始生成版本文件--------")
 
 	fmt.Println("写入版本文件：条目数量：", len(allDbVersion))
 
-	fileBytes, errJson := json.Marshal(VersionText{
-		CellList: allDbVersion,
-	})
+	version := VersionText{
+		CellList:    allDbVersion,
+		Generator:   BuildCache.GeneratorVersion,
+		PackVersion: info.PackVersion,
+	}
+	if !info.CreatedAt.IsZero() {
+		version.CreatedAt = info.CreatedAt.UTC().Format(time.RFC3339)
+	}
+
+	fileBytes, errJson := encodeManifest(version, info.SignKey)
 
 	if errJson != nil {
-		return errors.Errorf("生成版本文件失败  json.Marshal: %v", errJson)
+		return errors.Errorf("生成版本文件失败 %v", errJson)
 	}
 
-	newVersion.WriteString(string(fileBytes))
+	//客户端按version.txt下载数据库，写入失败时保留旧文件
+	if errWrite := filemode.WriteFileAtomic(dbPath+"version.txt", fileBytes, 0666); errWrite != nil {
+		return errors.Errorf("写入版本文件失败 %v", errWrite)
+	}
+
+	if info.SignKey != nil {
+		fmt.Println("版本文件已签名")
+	}
 
 	fmt.Println("--------版本文件生成结束--------")
 
@@ -0,0 +1,98 @@
+package VersionTxtGen
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildTime(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+
+	newest := time.Date(2024, 5, 6, 7, 8, 9, 500, time.UTC)
+	files := map[string]time.Time{
+		"Hero.xlsx":      newest.Add(-time.Hour),
+		"item/Item.tsv":  newest,
+		"readme.txt":     newest.Add(time.Hour),
+		"~$Hero.xlsx":    newest.Add(time.Hour),
+		"item/Drop.json": newest.Add(-2 * time.Hour),
+	}
+
+	for name, modTime := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+
+	got, err := BuildTime(dir)
+	if err != nil || !got.Equal(newest.Truncate(time.Second)) {
+		t.Errorf("BuildTime() = %v, %v, want newest table %v", got, err, newest.Truncate(time.Second))
+	}
+
+	empty, err := BuildTime(t.TempDir())
+	if err != nil || !empty.IsZero() {
+		t.Errorf("BuildTime(empty) = %v, %v, want zero", empty, err)
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	if got, err := BuildTime(dir); err != nil || got.Unix() != 1700000000 {
+		t.Errorf("BuildTime() with SOURCE_DATE_EPOCH = %v, %v", got, err)
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	if _, err := BuildTime(dir); err == nil {
+		t.Errorf("BuildTime() with bad SOURCE_DATE_EPOCH want error")
+	}
+}
+
+func TestGenerateVersionFileCreatedAt(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		want      string
+	}{
+		{name: "没有生成时间", want: ""},
+		{name: "UTC", createdAt: time.Date(2024, 1, 2, 11, 4, 5, 0, time.FixedZone("UTC+08:00", 8*3600)), want: "2024-01-02T03:04:05Z"},
+	}
+
+	for _, tt := range tests {
+		dir := filepath.ToSlash(t.TempDir()) + "/"
+
+		if err := GenerateVersionFile(dir, []MsgToDB{{MsgName: "HeroMain"}}, ManifestInfo{PackVersion: "1", CreatedAt: tt.createdAt}); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(dir + "version.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatal(err)
+		}
+
+		got, exist := fields["CreatedAt"]
+		if tt.want == "" {
+			if exist {
+				t.Errorf("%s: CreatedAt = %v, want omitted", tt.name, got)
+			}
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("%s: CreatedAt = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
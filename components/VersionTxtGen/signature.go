@@ -0,0 +1,137 @@
+package VersionTxtGen
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+)
+
+// ErrNotSigned version.txt没有签名，旧版本生成或者转表时没有指定签名私钥
+var ErrNotSigned = errors.New("版本文件没有签名")
+
+// encodeManifest 签名时先序列化不带签名的内容，签名追加为最后一个字段，校验时去掉这个字段就是签名内容，不依赖重新序列化
+func encodeManifest(version VersionText, key ed25519.PrivateKey) ([]byte, error) {
+	version.Signature = ""
+
+	payload, err := json.Marshal(version)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil {
+		return payload, nil
+	}
+
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+
+	signed := append([]byte{}, payload[:len(payload)-1]...)
+	signed = append(signed, signatureField(signature)...)
+
+	return signed, nil
+}
+
+func signatureField(signature string) string {
+	return `,"Signature":"` + signature + `"}`
+}
+
+// Verify 校验version.txt的签名，成功时返回解析后的内容
+// 没有签名时返回 ErrNotSigned，客户端可以按自己的策略决定是否接受
+func Verify(data []byte, publicKey ed25519.PublicKey) (*VersionText, error) {
+	version := &VersionText{}
+	if err := json.Unmarshal(data, version); err != nil {
+		return nil, errors.Errorf("解析版本文件失败 %v", err)
+	}
+
+	if version.Signature == "" {
+		return version, ErrNotSigned
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(version.Signature)
+	if err != nil {
+		return nil, errors.Errorf("签名格式错误 %v", err)
+	}
+
+	data = bytes.TrimSpace(data)
+	field := signatureField(version.Signature)
+	if !bytes.HasSuffix(data, []byte(field)) {
+		return nil, errors.Errorf("签名不是版本文件的最后一个字段")
+	}
+
+	payload := append(append([]byte{}, data[:len(data)-len(field)]...), '}')
+
+	if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, payload, signature) {
+		return nil, errors.Errorf("版本文件签名校验失败")
+	}
+
+	return version, nil
+}
+
+// LoadSignKey 读取ed25519私钥，支持PEM（PKCS8，openssl genpkey -algorithm ed25519）和base64的32字节种子或64字节私钥
+func LoadSignKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Errorf("读取签名私钥失败 %v", err)
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		key, errParse := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if errParse != nil {
+			return nil, errors.Errorf("解析签名私钥失败 %v", errParse)
+		}
+
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.Errorf("签名私钥不是ed25519")
+		}
+
+		return privateKey, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, errors.Errorf("解析签名私钥失败 %v", err)
+	}
+
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+
+	return nil, errors.Errorf("签名私钥长度错误 %d", len(raw))
+}
+
+// ParsePublicKey 解析ed25519公钥，支持PEM（PKIX）和base64的32字节公钥
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Errorf("解析公钥失败 %v", err)
+		}
+
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.Errorf("公钥不是ed25519")
+		}
+
+		return publicKey, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, errors.Errorf("解析公钥失败 %v", err)
+	}
+
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, errors.Errorf("公钥长度错误 %d", len(raw))
+	}
+
+	return ed25519.PublicKey(raw), nil
+}
@@ -0,0 +1,176 @@
+package VersionTxtGen
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(seed byte) ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+}
+
+func TestSignVerify(t *testing.T) {
+	key := testKey(1)
+	publicKey := key.Public().(ed25519.PublicKey)
+
+	version := VersionText{
+		CellList:    []MsgToDB{{MsgName: "HeroMain", FileName: "4/Hero_Main_x.db", Sha256: "ab", Size: 10, Rows: 2}},
+		Generator:   "3",
+		PackVersion: "7",
+		CreatedAt:   "2024-01-02T03:04:05Z",
+	}
+
+	signed, err := encodeManifest(version, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsigned, err := encodeManifest(version, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		publicKey ed25519.PublicKey
+		wantErr   error
+		fail      bool
+	}{
+		{name: "签名正确", data: signed, publicKey: publicKey},
+		{name: "末尾换行", data: append(append([]byte{}, signed...), '\n'), publicKey: publicKey},
+		{name: "修改数据库", data: bytes.Replace(signed, []byte("Hero_Main_x"), []byte("Hero_Main_y"), 1), publicKey: publicKey, fail: true},
+		{name: "修改版本号", data: bytes.Replace(signed, []byte(`"PackVersion":"7"`), []byte(`"PackVersion":"8"`), 1), publicKey: publicKey, fail: true},
+		{name: "不是json", data: []byte("{"), publicKey: publicKey, fail: true},
+		{name: "其他公钥", data: signed, publicKey: testKey(2).Public().(ed25519.PublicKey), fail: true},
+		{name: "公钥长度错误", data: signed, publicKey: publicKey[:16], fail: true},
+		{name: "没有签名", data: unsigned, publicKey: publicKey, wantErr: ErrNotSigned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify(tt.data, tt.publicKey)
+			if tt.fail {
+				if err == nil || err == ErrNotSigned {
+					t.Fatalf("Verify() error = %v, want verify failure", err)
+				}
+				return
+			}
+
+			if err != tt.wantErr {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got.PackVersion != version.PackVersion || len(got.CellList) != 1 || got.CellList[0] != version.CellList[0] {
+				t.Errorf("Verify() = %+v, want %+v", got, version)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureNotLast(t *testing.T) {
+	key := testKey(1)
+
+	signed, err := encodeManifest(VersionText{Generator: "3"}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 签名字段移到前面，内容不变但不是签名时的格式
+	version, _ := Verify(signed, key.Public().(ed25519.PublicKey))
+	field := signatureField(version.Signature)
+	moved := append([]byte(`{"Signature":"`+version.Signature+`",`), bytes.TrimSuffix(signed, []byte(field))[1:]...)
+	moved = append(moved, '}')
+
+	if _, err := Verify(moved, key.Public().(ed25519.PublicKey)); err == nil {
+		t.Errorf("Verify() with signature not last want error")
+	}
+}
+
+func TestLoadSignKey(t *testing.T) {
+	key := testKey(3)
+	dir := t.TempDir()
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "PEM", data: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})},
+		{name: "base64种子", data: []byte(base64.StdEncoding.EncodeToString(key.Seed()) + "\n")},
+		{name: "base64私钥", data: []byte(base64.StdEncoding.EncodeToString(key))},
+		{name: "长度错误", data: []byte(base64.StdEncoding.EncodeToString([]byte("short"))), wantErr: true},
+		{name: "不是base64", data: []byte("not a key"), wantErr: true},
+		{name: "PEM内容错误", data: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("bad")}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(file, tt.data, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadSignKey(file)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("LoadSignKey() want error")
+				}
+				return
+			}
+
+			if err != nil || !got.Equal(key) {
+				t.Errorf("LoadSignKey() = %v, %v", got, err)
+			}
+		})
+	}
+
+	if _, err := LoadSignKey(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("LoadSignKey() missing file want error")
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	publicKey := testKey(4).Public().(ed25519.PublicKey)
+
+	pkix, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "PEM", data: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})},
+		{name: "base64", data: []byte(base64.StdEncoding.EncodeToString(publicKey))},
+		{name: "长度错误", data: []byte(base64.StdEncoding.EncodeToString(publicKey[:31])), wantErr: true},
+		{name: "不是base64", data: []byte("???"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePublicKey(tt.data)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: ParsePublicKey() want error", tt.name)
+			}
+			continue
+		}
+
+		if err != nil || !got.Equal(publicKey) {
+			t.Errorf("%s: ParsePublicKey() = %v, %v", tt.name, got, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package WorkerPool
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Jobs 同时处理的表格数量，<=0 时使用CPU核数
+var Jobs = 0
+
+func workerCount(n int) int {
+	workers := Jobs
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	if workers > n {
+		workers = n
+	}
+
+	return workers
+}
+
+// Run 最多Jobs个goroutine处理下标 0~n-1 的任务，任务按下标顺序开始
+// fn返回错误时取消ctx，还没开始的任务不再执行，全部停止后返回第一个错误
+// 结果需要按顺序输出时由调用方按下标保存，不要依赖完成顺序
+func Run(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	if n <= 0 {
+		return nil
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	var firstErr error
+
+	indexes := make(chan int)
+
+	wg := &sync.WaitGroup{}
+	for w := workerCount(n); w > 0; w-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				index := i
+				errRun := Safe(func() error {
+					return fn(runCtx, index)
+				})
+
+				if errRun != nil {
+					once.Do(func() {
+						firstErr = errRun
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case <-runCtx.Done():
+			break feed
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return ctx.Err()
+}
+
+// Safe 执行fn，panic转换成错误返回，调用方可以和普通错误一样记录到转表报告
+func Safe(fn func() error) (err error) {
+	defer func() {
+		if errRecover := recover(); errRecover != nil {
+			fmt.Println("panic recover:", errRecover)
+			debug.PrintStack()
+			err = errors.Errorf("panic: %v", errRecover)
+		}
+	}()
+
+	return fn()
+}
@@ -0,0 +1,270 @@
+package XlsxAnnotate
+
+import (
+	"Tool-Library/components/BuildReport"
+	conf_tool "Tool-Library/components/conf-tool"
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/tealeg/xlsx"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 错误红色，警告黄色
+const (
+	errorColor   = "FFFF0000"
+	warningColor = "FFFFFF00"
+)
+
+// 报告作者，批注中显示
+var Author = "conf"
+
+type cellMark struct {
+	row, col int
+	color    string
+	messages []string
+}
+
+// WriteAnnotated 报告中有错误或者警告的表格生成带标记的副本到outDir，返回生成的文件
+func WriteAnnotated(confPath string, outDir string, report *BuildReport.Report) ([]string, error) {
+	confPath = conf_tool.TransPath(confPath)
+	outDir = conf_tool.TransPath(outDir)
+
+	fileEntries := map[string][]BuildReport.Entry{}
+	for _, e := range report.Sorted() {
+		//只标记xlsx，文本表和ods没有单元格样式和批注，xlsm重新保存会丢失宏
+		if e.File == "" || e.Sheet == "" || strings.ToLower(filepath.Ext(e.File)) != ".xlsx" {
+			continue
+		}
+		fileEntries[e.File] = append(fileEntries[e.File], e)
+	}
+
+	var files []string
+	for file := range fileEntries {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	var written []string
+
+	for _, file := range files {
+		data, err := os.ReadFile(confPath + file)
+		if err != nil {
+			return written, errors.Errorf("读取表格失败 %v %v", file, err)
+		}
+
+		annotated, err := AnnotateWorkbook(data, fileEntries[file])
+		if err != nil {
+			return written, errors.Errorf("标记表格失败 %v %v", file, err)
+		}
+
+		if err := conf_tool.WriteFile(outDir+file, annotated); err != nil {
+			return written, errors.Errorf("写入标记表格失败 %v %v", file, err)
+		}
+
+		written = append(written, outDir+file)
+	}
+
+	return written, nil
+}
+
+// AnnotateWorkbook 错误单元格红色填充（警告黄色）并加上批注，不是具体单元格的页签错误标记在A1
+func AnnotateWorkbook(data []byte, entries []BuildReport.Entry) ([]byte, error) {
+	file, err := xlsx.OpenBinary(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "OpenBinary")
+	}
+
+	// key=页签序号
+	sheetMarks := map[int][]*cellMark{}
+
+	for _, e := range entries {
+		sheetIndex := -1
+		for i, sheet := range file.Sheets {
+			if sheet.Name == e.Sheet {
+				sheetIndex = i
+				break
+			}
+		}
+
+		if sheetIndex == -1 {
+			continue
+		}
+
+		row, col := e.Row-1, e.Col-1
+		if row < 0 || col < 0 {
+			row, col = 0, 0
+		}
+
+		var mark *cellMark
+		for _, m := range sheetMarks[sheetIndex] {
+			if m.row == row && m.col == col {
+				mark = m
+				break
+			}
+		}
+
+		if mark == nil {
+			mark = &cellMark{row: row, col: col, color: warningColor}
+			sheetMarks[sheetIndex] = append(sheetMarks[sheetIndex], mark)
+		}
+
+		if e.Severity == BuildReport.SeverityError {
+			mark.color = errorColor
+		}
+
+		mark.messages = append(mark.messages, e.Severity+": "+e.Message)
+	}
+
+	for sheetIndex, marks := range sheetMarks {
+		sheet := file.Sheets[sheetIndex]
+		for _, m := range marks {
+			fillCell(sheet, m.row, m.col, m.color)
+		}
+	}
+
+	parts, err := file.MarshallParts()
+	if err != nil {
+		return nil, errors.Wrapf(err, "MarshallParts")
+	}
+
+	var sheetIndexes []int
+	for sheetIndex := range sheetMarks {
+		sheetIndexes = append(sheetIndexes, sheetIndex)
+	}
+	sort.Ints(sheetIndexes)
+
+	var commentTypes []string
+
+	for _, sheetIndex := range sheetIndexes {
+		n := sheetIndex + 1
+		sheetPart := fmt.Sprintf("xl/worksheets/sheet%d.xml", n)
+
+		sheetXml, exist := parts[sheetPart]
+		if !exist {
+			return nil, errors.Errorf("找不到页签 %v", sheetPart)
+		}
+
+		sheetXml = strings.Replace(sheetXml, `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"`,
+			`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`, 1)
+		sheetXml = strings.Replace(sheetXml, "</worksheet>", `<legacyDrawing r:id="rId2"/></worksheet>`, 1)
+		parts[sheetPart] = sheetXml
+
+		parts[fmt.Sprintf("xl/worksheets/_rels/sheet%d.xml.rels", n)] = xml.Header +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			fmt.Sprintf(`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments" Target="../comments%d.xml"/>`, n) +
+			fmt.Sprintf(`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/vmlDrawing" Target="../drawings/vmlDrawing%d.vml"/>`, n) +
+			`</Relationships>`
+
+		parts[fmt.Sprintf("xl/comments%d.xml", n)] = commentsXml(sheetMarks[sheetIndex])
+		parts[fmt.Sprintf("xl/drawings/vmlDrawing%d.vml", n)] = vmlXml(n, sheetMarks[sheetIndex])
+
+		commentTypes = append(commentTypes, fmt.Sprintf(`<Override PartName="/xl/comments%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.comments+xml"/>`, n))
+	}
+
+	if len(commentTypes) > 0 {
+		commentTypes = append(commentTypes, `<Default Extension="vml" ContentType="application/vnd.openxmlformats-officedocument.vmlDrawing"/>`)
+		parts["[Content_Types].xml"] = strings.Replace(parts["[Content_Types].xml"], "</Types>", strings.Join(commentTypes, "")+"</Types>", 1)
+	}
+
+	return zipParts(parts)
+}
+
+func fillCell(sheet *xlsx.Sheet, row, col int, color string) {
+	for len(sheet.Rows) <= row {
+		sheet.AddRow()
+	}
+
+	r := sheet.Rows[row]
+	for len(r.Cells) <= col {
+		r.AddCell()
+	}
+
+	cell := r.Cells[col]
+
+	// 复制一份，样式可能是多个单元格共用的
+	style := *cell.GetStyle()
+	style.Fill = *xlsx.NewFill("solid", color, color)
+	style.ApplyFill = true
+	cell.SetStyle(&style)
+}
+
+func escape(s string) string {
+	buf := &bytes.Buffer{}
+	xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
+
+func commentsXml(marks []*cellMark) string {
+	sb := strings.Builder{}
+
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<comments xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	sb.WriteString(`<authors><author>` + escape(Author) + `</author></authors><commentList>`)
+
+	for _, m := range marks {
+		sb.WriteString(`<comment ref="` + xlsx.GetCellIDStringFromCoords(m.col, m.row) + `" authorId="0"><text><r><t xml:space="preserve">`)
+		sb.WriteString(escape(strings.Join(m.messages, "\n")))
+		sb.WriteString(`</t></r></text></comment>`)
+	}
+
+	sb.WriteString(`</commentList></comments>`)
+
+	return sb.String()
+}
+
+func vmlXml(sheetNum int, marks []*cellMark) string {
+	sb := strings.Builder{}
+
+	sb.WriteString(`<xml xmlns:v="urn:schemas-microsoft-com:vml" xmlns:o="urn:schemas-microsoft-com:office:office" xmlns:x="urn:schemas-microsoft-com:office:excel">`)
+	sb.WriteString(`<o:shapelayout v:ext="edit"><o:idmap v:ext="edit" data="` + strconv.Itoa(sheetNum) + `"/></o:shapelayout>`)
+	sb.WriteString(`<v:shapetype id="_x0000_t202" coordsize="21600,21600" o:spt="202" path="m,l,21600r21600,l21600,xe">`)
+	sb.WriteString(`<v:stroke joinstyle="miter"/><v:path gradientshapeok="t" o:connecttype="rect"/></v:shapetype>`)
+
+	for i, m := range marks {
+		sb.WriteString(fmt.Sprintf(`<v:shape id="_x0000_s%d" type="#_x0000_t202" style="position:absolute;margin-left:60pt;margin-top:2pt;width:200pt;height:80pt;z-index:%d;visibility:hidden" fillcolor="#ffffe1" o:insetmode="auto">`, sheetNum*1024+i+1, i+1))
+		sb.WriteString(`<v:fill color2="#ffffe1"/><v:shadow on="t" color="black" obscured="t"/><v:path o:connecttype="none"/>`)
+		sb.WriteString(`<v:textbox style="mso-direction-alt:auto"><div style="text-align:left"></div></v:textbox>`)
+		sb.WriteString(`<x:ClientData ObjectType="Note"><x:MoveWithCells/><x:SizeWithCells/>`)
+		sb.WriteString(fmt.Sprintf(`<x:Anchor>%d, 15, %d, 2, %d, 15, %d, 16</x:Anchor>`, m.col+1, m.row, m.col+4, m.row+4))
+		sb.WriteString(fmt.Sprintf(`<x:AutoFill>False</x:AutoFill><x:Row>%d</x:Row><x:Column>%d</x:Column></x:ClientData></v:shape>`, m.row, m.col))
+	}
+
+	sb.WriteString(`</xml>`)
+
+	return sb.String()
+}
+
+func zipParts(parts map[string]string) ([]byte, error) {
+	var names []string
+	for name := range parts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := f.Write([]byte(parts[name])); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"io"
-	"io/fs"
 	"os"
 	"os/exec"
 	"path"
@@ -25,39 +24,37 @@ func TransPath(path string) string {
 	return path
 }
 
+// ZipXlsxFiles 压缩目录（包括子目录）下的表格（包括csv、tsv、json文本表），zip中保留相对路径
 func ZipXlsxFiles(root string) ([]byte, error) {
 	root = TransPath(root)
 
 	fileMap := make(map[string][]byte)
-	handleFile := func(root, zipPrefix string, f fs.FileInfo) error {
-		if f.IsDir() {
-			return nil
-		}
-		if !strings.HasSuffix(f.Name(), ".xlsx") && !strings.HasSuffix(f.Name(), ".txt") {
+	handleFile := func(root, name string) error {
+		if strings.HasPrefix(path.Base(name), "~$") {
 			return nil
 		}
 
-		if strings.HasPrefix(f.Name(), "~$") {
+		if !config.IsTableFile(name) && !strings.HasSuffix(name, ".txt") {
 			return nil
 		}
 
-		filename := filepath.Join(root, f.Name())
+		filename := filepath.Join(root, name)
 		data, err := os.ReadFile(filename)
 		if err != nil {
 			return errors.Wrapf(err, "读取文件失败，file: %s", filename)
 		}
 
 		// 压缩文件
-		fileMap[zipPrefix+f.Name()] = data
+		fileMap[name] = data
 		return nil
 	}
 
-	fs, err := config.ReadDir(root)
+	fs, err := config.WalkDir(root)
 	if err != nil {
 		return nil, errors.Wrapf(err, "读取文件夹失败，root: %s", root)
 	}
 	for _, f := range fs {
-		if err := handleFile(root, "", f); err != nil {
+		if err := handleFile(root, f); err != nil {
 			return nil, err
 		}
 	}
@@ -99,37 +96,34 @@ func packData(fileBytes map[string][]byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// ZipFiles 压缩目录（包括子目录）下的所有文件，zip中保留相对路径
 func ZipFiles(root string) ([]byte, error) {
 
 	root = TransPath(root)
 
 	fileMap := make(map[string][]byte)
-	handleFile := func(root, zipPrefix string, f fs.FileInfo) error {
-		if f.IsDir() {
-			return nil
-		}
-
-		if strings.HasPrefix(f.Name(), "~$") {
+	handleFile := func(root, name string) error {
+		if strings.HasPrefix(path.Base(name), "~$") {
 			return nil
 		}
 
-		filename := filepath.Join(root, f.Name())
+		filename := filepath.Join(root, name)
 		data, err := os.ReadFile(filename)
 		if err != nil {
 			return errors.Wrapf(err, "读取文件失败，file: %s", filename)
 		}
 
 		// 压缩文件
-		fileMap[zipPrefix+f.Name()] = data
+		fileMap[name] = data
 		return nil
 	}
 
-	fs, err := config.ReadDir(root)
+	fs, err := config.WalkDir(root)
 	if err != nil {
 		return nil, errors.Wrapf(err, "读取文件夹失败，root: %s", root)
 	}
 	for _, f := range fs {
-		if err := handleFile(root, "", f); err != nil {
+		if err := handleFile(root, f); err != nil {
 			return nil, err
 		}
 	}
@@ -171,6 +165,7 @@ func UnpackData(data []byte) (map[string][]byte, error) {
 	return fileBytes, nil
 }
 
+// WriteFile 原子写入，转表中途退出时不会留下写了一半的文件
 func WriteFile(filename string, data []byte) error {
 	if len(data) == 0 {
 		return nil
@@ -181,13 +176,7 @@ func WriteFile(filename string, data []byte) error {
 		return err
 	}
 
-	_, errIsExist := os.Stat(filename)
-
-	if !os.IsNotExist(errIsExist) {
-		os.Remove(filename)
-	}
-
-	return os.WriteFile(filename, data, 777)
+	return filemode.WriteFileAtomic(filename, data, 777)
 }
 
 func RunCommand(name string, arg ...string) error {
@@ -1,22 +1,23 @@
 package excel_to_proto
 
 import (
+	"Tool-Library/components/BuildCache"
+	"Tool-Library/components/BuildReport"
+	"Tool-Library/components/ColumnType"
 	"Tool-Library/components/ProtoIDGen"
+	"Tool-Library/components/WorkerPool"
 	conf_tool "Tool-Library/components/conf-tool"
 	"Tool-Library/components/filemode"
 	"Tool-Library/components/md5"
-	"encoding/json"
+	"Tool-Library/shared/config"
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
-	"github.com/tealeg/xlsx"
 	"os"
 	"path/filepath"
-	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
 	"time"
 )
 
@@ -27,9 +28,19 @@ var ProtoVersionName = "ProtoVersion.json"
 type ProtoVersion struct {
 	ExcelMd5  string
 	ProtoName map[string]struct{}
+
+	// 生成proto的页签，用来检查消息名重复，不需要重新打开表格
+	Sheets []string `json:",omitempty"`
+
+	// 每个页签的缓存key，proto内容没有变化的页签不重新写入
+	SheetKeys map[string]BuildCache.Key `json:",omitempty"`
 }
 
-func GenerateExcelToProto(confPath string, idGenPath string, ProtoPath string) error {
+// ErrMessageNameConflict 不同表格生成了同名的消息，proto和数据库会互相覆盖
+var ErrMessageNameConflict = errors.New("不同表格生成的proto消息名重复")
+
+// GenerateExcelToProto 返回生成的消息描述，生成数据库时直接使用，加载ProtoID记录失败时返回nil
+func GenerateExcelToProto(tables *config.TableCache, idGenPath string, ProtoPath string, report *BuildReport.Report) (*Schemas, error) {
 
 	fmt.Println("--------开始加载ProtoID记录--------")
 	//加载旧ProtoID表进来
@@ -38,37 +49,23 @@ func GenerateExcelToProto(confPath string, idGenPath string, ProtoPath string) e
 	fmt.Println("加载ProtoID记录耗时：", time.Since(timeLoad))
 
 	if errLoadGen != nil {
-		return errors.Errorf("加载ProtoID记录失败，idGenNamePath：%v  Err:%v ", idGenPath, errLoadGen)
+		return nil, errors.Errorf("加载ProtoID记录失败，idGenNamePath：%v  Err:%v ", idGenPath, errLoadGen)
 	}
 
 	fmt.Println("--------加载ProtoID记录成功--------")
 
 	fmt.Println("--------开始加载ProtoMd5Json--------")
 
-	protoVersionPath := ProtoPath + ProtoVersionName
-
-	protoVersionJson, errProtoVersion := os.ReadFile(protoVersionPath)
-
-	if errProtoVersion != nil && os.IsNotExist(errProtoVersion) {
-		//不存在直接创建
-		fmt.Println("对应路径下不存在ProtoVersion，直接创建,路径：", protoVersionPath)
-		fp, errCreate := filemode.Create(protoVersionPath, 777) // 如果文件已存在，会将文件清空。
-		// defer延迟调用
-		defer fp.Close()
-
-		if errCreate != nil {
-			return errors.Errorf("创建在对应路径下不存在ProtoVersion失败，Err: %v", errCreate)
-		}
-
-		protoVersionJson, errProtoVersion = os.ReadFile(protoVersionPath)
-		if errProtoVersion != nil {
-			return errors.Errorf("创建在ProtoID记录后，重新读取失败: %v", errProtoVersion)
-		}
+	protoVersionData, errLoad := LoadProtoVersion(ProtoPath)
+	if errLoad != nil {
+		//缓存损坏时重新写入所有proto
+		fmt.Println("ProtoVersion读取失败，重新生成所有proto：", errLoad)
+		protoVersionData = map[string]ProtoVersion{}
 	}
 
-	protoVersionData := map[string]ProtoVersion{}
-
-	json.Unmarshal(protoVersionJson, &protoVersionData)
+	for _, invalid := range ValidateProtoVersion(ProtoPath, protoVersionData) {
+		fmt.Println("ProtoVersion记录无效：", invalid)
+	}
 
 	fmt.Println("--------加载ProtoMd5Json结束--------")
 
@@ -76,32 +73,32 @@ func GenerateExcelToProto(confPath string, idGenPath string, ProtoPath string) e
 
 	//加载表去生成对应proto
 	timeGenerate := time.Now()
-	errGenerate := ReadDirToGenerateProto(protoIdGen, confPath, ProtoPath, "", protoVersionData)
+	schemas := NewSchemas(ProtoPath, protoIdGen)
+	errGenerate := ReadDirToGenerateProto(protoIdGen, tables, ProtoPath, "", protoVersionData, schemas, report)
 	fmt.Println("生成Proto耗时：", time.Since(timeGenerate))
 
-	if errGenerate != nil {
-		return errors.Errorf("生成proto失败 Err:%v ", errGenerate)
-	}
-
 	fmt.Println("--------proto生成结束--------")
 
-	ProtoIDGen.SaveGen(protoIdGen, idGenPath)
+	//有表格失败时也保存，已经写出的proto和后面生成的数据库使用了这次分配的ProtoID，下次转表必须分配同样的ID
+	if errSave := ProtoIDGen.SaveGen(protoIdGen, idGenPath); errSave != nil {
+		return schemas, errSave
+	}
 
 	fmt.Println("更新记录ProtoVersion数量：")
-	jsonBytes, errJson := json.Marshal(protoVersionData)
-
-	if errJson != nil {
-		return errors.Errorf("序列化protoVersionData报错 json.Marshal: %v", errJson)
+	if errWrite := SaveProtoVersion(ProtoPath, protoVersionData); errWrite != nil {
+		return schemas, errors.Errorf("ProtoVersion 写入JSON失败, %v", errWrite)
 	}
 
-	if errWrite := conf_tool.WriteFile(protoVersionPath, jsonBytes); errWrite != nil {
-		return errors.Errorf("DBVersion 写入JSON失败, %v", errWrite)
+	if errGenerate != nil {
+		return schemas, errors.Wrapf(errGenerate, "生成proto失败")
 	}
 
-	return nil
+	return schemas, nil
 }
 
-func ReadDirToGenerateProto(protoIdGen *ProtoIDGen.ProtoIdGen, confPath string, ProtoPath string, csPath string, protoVersionData map[string]ProtoVersion) error {
+// ReadDirToGenerateProto 所有表格的错误都记录到report，全部处理完之后再返回
+// 表格通过tables读取，同一次转表中后面生成数据库不需要重新解析；schemas不为nil时记录生成的消息描述
+func ReadDirToGenerateProto(protoIdGen *ProtoIDGen.ProtoIdGen, tables *config.TableCache, ProtoPath string, csPath string, protoVersionData map[string]ProtoVersion, schemas *Schemas, report *BuildReport.Report) error {
 	errorCreateProto := filemode.MkdirAll(ProtoPath, 777)
 
 	if errorCreateProto != nil {
@@ -125,265 +122,362 @@ func ReadDirToGenerateProto(protoIdGen *ProtoIDGen.ProtoIdGen, confPath string,
 		}
 	}
 
-	if strings.HasSuffix(confPath, "/") {
-		confPath = confPath[:len(confPath)-1]
-	}
-	dirWithSep := confPath + "/"
+	dirWithSep := tables.Dir()
 
-	if fss, err := os.ReadDir(dirWithSep); err != nil {
+	//包括子目录中的表格，fName是相对配置目录的路径
+	if fss, err := config.WalkDir(dirWithSep); err != nil {
 		return errors.Errorf("GenerateProto error 生成失败读取文件, %v", err)
 	} else {
 
-		wg := &sync.WaitGroup{}
-		var loadErrorRef atomic.Value
+		//不同表格的消息名重复时直接停止，生成的proto会互相覆盖
+		if errCheck := checkMessageNames(tables, fss, protoVersionData, report); errCheck != nil {
+			return errCheck
+		}
+
+		var files []string
+		for _, fName := range fss {
+			if config.IsTableFile(fName) {
+				files = append(files, fName)
+			}
+		}
+
+		//每个表格的结果按下标保存，全部结束后按文件顺序写入protoVersionData
+		versions := make([]*ProtoVersion, len(files))
+
+		stats := BuildCache.NewStats("Proto")
 
-		loadMux := &sync.Mutex{}
 		timeGen := time.Now()
-		for _, f := range fss {
+		errRun := WorkerPool.Run(context.Background(), len(files), func(ctx context.Context, i int) error {
+			fName := files[i]
+			path := dirWithSep + fName
 
-			fName := f.Name()
+			errGen := WorkerPool.Safe(func() error {
+				version, errTable := genProtoByTable(tables, path, fName, ProtoPath, csPath, protoIdGen, protoVersionData, schemas, stats, report)
+				versions[i] = version
+				return errTable
+			})
 
-			if filepath.Ext(fName) != ".xlsx" {
-				continue
+			if errGen != nil {
+				report.Error(fName, "", 0, 0, "genProtoByTable 表格：%v 生成Proto失败 Error：%v", path, errGen)
 			}
 
-			if strings.Contains(fName, "~$") {
-				continue
-			}
+			return errGen
+		})
+		fmt.Println("多线程生成Proto耗时：", time.Since(timeGen))
+		stats.PrintSummary()
 
-			path := dirWithSep + fName
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				defer func() {
-					if errRecover := recover(); errRecover != nil {
-						fmt.Println("GenerateProto error 生成失败,Err:", errRecover)
-						debug.PrintStack()
-					}
-				}()
-
-				loadMux.Lock()
-				defer loadMux.Unlock()
-
-				//timeOneGen := time.Now()
-				errGen := genProtoByTable(path, ProtoPath, csPath, protoIdGen, protoVersionData)
-				//fmt.Println("线程生成path:"+path+" Proto耗时：", time.Since(timeOneGen))
-
-				if errGen != nil {
-					loadErrorRef.Store(errors.Errorf("genProtoByTable 表格：%v 生成Proto失败 Error：%v", path, errGen))
-					return
-				}
-			}()
+		for i, version := range versions {
+			if version != nil {
+				protoVersionData[strings.TrimSuffix(files[i], filepath.Ext(files[i]))] = *version
+			}
 		}
 
-		wg.Wait()
-		fmt.Println("多线程生成Proto耗时：", time.Since(timeGen))
+		if errRun != nil {
+			return errors.Wrapf(errRun, "多线程生成Proto中断")
+		}
 
-		if loadError := loadErrorRef.Load(); loadError != nil {
-			return errors.Errorf("多线程生成Proto,error, %v", loadError)
+		if errReport := report.Err(); errReport != nil {
+			return errors.Errorf("多线程生成Proto,error, %v", errReport)
 		}
 	}
 
 	return nil
 }
 
-func genProtoByTable(path string, ProtoPath string, csPath string, protoIdGen *ProtoIDGen.ProtoIdGen, protoVersionData map[string]ProtoVersion) error {
+// genProtoByTable 多个表格并发调用，protoVersionData只读，需要更新的记录通过返回值给调用方写入
+// 每个页签按生成的proto内容判断是否需要重新写入，cs每次都生成
+// 表格内容的错误记录到report后返回nil，返回的错误（读写文件、protoc失败）会中断所有表格的生成
+func genProtoByTable(tables *config.TableCache, path string, fName string, ProtoPath string, csPath string, protoIdGen *ProtoIDGen.ProtoIdGen, protoVersionData map[string]ProtoVersion, schemas *Schemas, stats *BuildCache.Stats, report *BuildReport.Report) (*ProtoVersion, error) {
 
-	data, errFileTable := os.ReadFile(path)
+	data, errFileTable := tables.Data(fName)
 
 	if errFileTable != nil {
-		return errors.Errorf("GenerateProto error 读取文件失败,path:%v %v", path, errFileTable)
+		return nil, errors.Wrapf(errFileTable, "GenerateProto error")
 	}
 
-	//判定是否继续生成
-
-	//获取文件名带后缀
-	filenameWithSuffix := filepath.Base(path)
+	//获取文件名带后缀，子目录中的表格带目录，例如 hero/Hero.xlsx
+	filenameWithSuffix := fName
 	//获取文件后缀
-	fileSuffix := filepath.Ext(path)
+	fileSuffix := filepath.Ext(fName)
 	//获取文件名
 	filenameOnly := strings.TrimSuffix(filenameWithSuffix, fileSuffix)
 
-	needGen := true
+	oldVersion := protoVersionData[filenameOnly]
 
-	if _, isOk := protoVersionData[filenameOnly]; isOk {
-		v := protoVersionData[filenameOnly]
+	fmt.Println("开始生成表格Proto：", path)
 
-		if v.ExcelMd5 == md5.String(data) && len(v.ProtoName) != 0 {
-			needGen = false
-			//虽然不需要读取数据了，但是 cs还是需要生成
+	//xlsx按list页签读取，csv、tsv、json只有一个页签
+	table, errOpen := tables.Table(fName)
 
-			if csPath != "" {
+	if errOpen != nil {
+		report.Error(fName, "", 0, 0, "读取表格失败 表名：%s %v", path, errOpen)
+		return nil, nil
+	}
 
-				wg := &sync.WaitGroup{}
-				var loadErrorRef atomic.Value
+	protoNameMap := map[string]struct{}{}
+	sheetKeys := map[string]BuildCache.Key{}
+	var sheetNames []string
 
-				for pStr, _ := range v.ProtoName {
+	//页签错误记录到report后继续处理下一个页签，有错误不更新ProtoVersion
+	hasSheetErr := false
 
-					protoName := pStr
+	listRows := table.ListRows
 
-					wg.Add(1)
-					go func() {
-						defer wg.Done()
+	for i := 0; i < len(listRows); i++ {
+		sheetRow := listRows[i]
 
-						fmt.Println("生成协议文件：", ProtoPath+protoName)
+		if len(sheetRow) < 1 {
+			continue
+		}
+		sheetName := sheetRow[0]
 
-						errRun := conf_tool.RunCommand("protoc", "--csharp_out="+csPath, ProtoPath+protoName)
+		curSheet := table.Sheet(sheetName)
 
-						if errRun != nil {
-							loadErrorRef.Store(errors.Errorf("protoc 生成cs失败 Error：%v", errRun))
-							return
-						}
-					}()
-				}
+		if curSheet == nil {
+			fmt.Println("找不到sheet 表名：[", path, "] sheetName  [", sheetName, "]跳过")
+			report.Warning(filenameWithSuffix, sheetName, 0, 0, "list中配置的sheet不存在，跳过")
+			continue
+		}
 
-				wg.Wait()
+		rows, errRead := curSheet.Rows()
+		if errRead != nil {
+			report.Error(filenameWithSuffix, sheetName, 0, 0, "读取表格数据失败 表名：%s %v", path, errRead)
+			hasSheetErr = true
+			continue
+		}
 
-				if loadError := loadErrorRef.Load(); loadError != nil {
-					return errors.Errorf("多线程生成Proto,error, %v", loadError)
-				}
+		if len(rows) < starReadLine {
+			report.Error(filenameWithSuffix, sheetName, 0, 0, "表格行数不足跳过 表名：%v 页签名称：%v 行数：%d ，最低行数要求：%d", path, sheetName, len(rows), starReadLine)
+			hasSheetErr = true
+			continue
+		}
+
+		source, errGenProto := SheetProto(fName, sheetName, rows, ProtoPath, protoIdGen, report)
+
+		if errGenProto != nil {
+			report.Error(filenameWithSuffix, sheetName, 0, 0, "GenProtoTomessage 生成各自 proto失败 %v", errGenProto)
+			hasSheetErr = true
+			continue
+		}
+
+		//生成数据库直接使用内存中的消息描述，proto文件只是输出
+		if schemas != nil {
+			if _, errParse := schemas.add(filenameOnly, sheetName, source); errParse != nil {
+				report.Error(filenameWithSuffix, sheetName, 0, 0, "解析生成的proto失败 %v", errParse)
+				hasSheetErr = true
+				continue
 			}
+		}
 
-			return nil
+		protoFileName := ProtoIDGen.GetProtoFileName(filenameOnly, sheetName)
+
+		itselfProto := ProtoPath + protoFileName
+
+		key := BuildCache.NewKey("", BuildCache.Digest(source), "")
+		reason := key.Reason(oldVersion.SheetKeys[sheetName])
+		if _, errStat := os.Stat(itselfProto); reason == "" && errStat != nil {
+			reason = "proto文件不存在"
 		}
-	}
 
-	if !needGen {
-		//fmt.Println("表格数据没有变化，不需要生成Proto：", path)
+		if reason == "" {
+			stats.Hit(filenameWithSuffix, sheetName)
+		} else {
+			stats.Miss(filenameWithSuffix, sheetName, reason)
 
-		return nil
-	}
+			if errMkdir := filemode.MkdirAll(filepath.Dir(itselfProto), 777); errMkdir != nil {
+				return nil, errors.Errorf("创建proto目录失败 %v", errMkdir)
+			}
 
-	fmt.Println("开始生成表格Proto：", path)
+			errWrite := filemode.WriteFileAtomic(itselfProto, []byte(source), 0777)
+
+			if errWrite != nil {
+				return nil, errors.Errorf("写入各自proto文件失败 %v", errWrite)
+			}
+		}
 
-	file, errOpenBinary := xlsx.OpenBinary(data)
+		if csPath != "" {
+			errRun := runProtocCs(csPath, itselfProto)
 
-	if errOpenBinary != nil {
-		return errors.Wrapf(errOpenBinary, "解析表格数据失败 OpenBinary 表名：%s", path)
+			if errRun != nil {
+				return nil, errors.Errorf("本次版本生成cs文件失败 %v", errRun)
+			}
+		}
+
+		protoNameMap[protoFileName] = struct{}{}
+		sheetKeys[sheetName] = key
+		sheetNames = append(sheetNames, sheetName)
 	}
 
-	// 找到需要处理sheet（读取sheet(list)）
-	ListSheet := file.Sheet["list"]
-	if ListSheet == nil {
-		return errors.Errorf("表格数据中没有找到list页签 表名：%s ", path)
+	if hasSheetErr {
+		return nil, nil
 	}
 
-	protoNameMap := map[string]struct{}{}
+	return &ProtoVersion{
+		ExcelMd5:  protoSourceMd5(data),
+		ProtoName: protoNameMap,
+		Sheets:    sheetNames,
+		SheetKeys: sheetKeys,
+	}, nil
+}
+
+// SheetProto 页签生成的proto文件内容，rows至少有 starReadLine 行
+func SheetProto(fName string, sheetName string, rows [][]string, ProtoPath string, protoIdGen *ProtoIDGen.ProtoIdGen, report *BuildReport.Report) (string, error) {
+	filenameOnly := strings.TrimSuffix(fName, filepath.Ext(fName))
 
-	for i := 0; i < len(ListSheet.Rows); i++ {
-		sheetRow := ListSheet.Rows[i]
+	memberMap := make(map[string]string)
 
-		if len(sheetRow.Cells) < 1 {
+	titleRow := rows[1]
+	for j := 0; j < len(titleRow); j++ {
+
+		title := titleRow[j]
+
+		if title == "" {
 			continue
 		}
-		sheetName := sheetRow.Cells[0].String()
 
-		curSheet := file.Sheet[strings.TrimSpace(sheetName)]
+		titleType := strings.TrimSpace(strings.ToLower(config.SheetCell(rows, 2, j)))
 
-		if curSheet == nil {
-			fmt.Println("找不到sheet 表名：[", path, "] sheetName  [", sheetName, "]跳过")
+		if titleType == "" {
+			fmt.Printf("表格列类型为空跳过 表名：%v 页签名称：%v 列数：%d \n", fName, sheetName, j)
+			report.Warning(fName, sheetName, 3, j+1, "列[%v]类型为空，跳过", title)
 			continue
 		}
 
-		memberMap := make(map[string]string)
+		colType := ColumnType.Parse(titleType)
+		protoType := colType.ProtoType() //不会出现NULL
 
-		if len(curSheet.Rows) < starReadLine {
-			return errors.Errorf("表格行数不足跳过 表名：%v 页签名称：%v 行数：%d ，最低行数要求：%d \n", path, sheetName, len(curSheet.Rows), starReadLine)
+		if protoType != "" {
+			if memberMap[title] != "" {
+				if !strings.HasPrefix(memberMap[title], "repeated ") {
+					//同名多列合并成数组，数组不支持optional
+					memberMap[title] = "repeated " + colType.ScalarProtoType()
+				} else {
+					//已经repeated过了
+				}
+			} else {
+				memberMap[title] = protoType
+			}
 		}
+	}
 
-		titleRow := curSheet.Rows[1]
-		for j := 0; j < len(titleRow.Cells); j++ {
+	builder := strings.Builder{}
 
-			title := titleRow.Cells[j].String()
+	//消息头部
+	builder.WriteString(ProtoIDGen.GetProtoHeader(ProtoPath, filenameOnly))
 
-			if title == "" {
-				continue
-			}
+	//时间类型需要import
+	importMap := map[string]struct{}{}
+	for _, memberType := range memberMap {
+		if protoImport := ColumnType.ProtoImport(memberType); protoImport != "" {
+			importMap[protoImport] = struct{}{}
+		}
+	}
 
-			if len(curSheet.Rows) < 3 {
-				//fmt.Printf("表格行数不足跳过 表名：%v 页签名称：%v 行数：%d \n", path, sheetName, len(curSheet.Rows))
-				continue
-			}
+	imports := make([]string, 0, len(importMap))
+	for protoImport := range importMap {
+		imports = append(imports, protoImport)
+	}
+	sort.Strings(imports)
 
-			titleType := strings.TrimSpace(strings.ToLower(curSheet.Rows[2].Cells[j].String()))
+	for _, protoImport := range imports {
+		builder.WriteString("\n\nimport \"" + protoImport + "\";")
+	}
 
-			if titleType == "" {
-				fmt.Printf("表格列类型为空跳过 表名：%v 页签名称：%v 列数：%d \n", path, sheetName, j)
-				continue
-			}
+	if errGenProto := GenProtoTomessage(fName, sheetName, memberMap, &builder, protoIdGen); errGenProto != nil {
+		return "", errGenProto
+	}
 
-			protoType := getProtoType(titleType) //不会出现NULL
+	return builder.String(), nil
+}
 
-			if protoType != "" {
-				if memberMap[title] != "" {
-					if len(strings.Split(memberMap[title], " ")) == 1 {
-						memberMap[title] = "repeated " + protoType
-					} else {
-						//已经repeated过了
-					}
-				} else {
-					memberMap[title] = protoType
-				}
-			}
-		}
+// protoSourceMd5 命名规则、package规则和嵌套消息开关会影响proto，规则变化后需要重新生成
+func protoSourceMd5(data []byte) string {
+	signature := config.Naming.Signature() + "|" + strconv.FormatBool(ProtoIDGen.PackagePerWorkbook) + "|" + strconv.FormatBool(ProtoIDGen.NestedMessage)
+	return md5.String(append([]byte(signature), data...))
+}
 
-		messageName := ProtoIDGen.GetMessageName(filenameOnly, sheetName)
+// runProtocCs 按表格分package时C#文件按namespace放到子目录，不同package的同名消息不会互相覆盖
+func runProtocCs(csPath string, protoFile string) error {
+	if ProtoIDGen.PackagePerWorkbook {
+		return conf_tool.RunCommand("protoc", "--csharp_out="+csPath, "--csharp_opt=base_namespace=Conf", protoFile)
+	}
 
-		builder := strings.Builder{}
+	return conf_tool.RunCommand("protoc", "--csharp_out="+csPath, protoFile)
+}
 
-		itselfProto := ProtoPath + messageName + ".proto"
+// checkMessageNames 生成之前检查所有表格的消息名，没有变化的表格使用ProtoVersion中记录的页签
+// 同一个目录下文件名相同、后缀不同的表格（例如 Item.xlsx 和 Item.csv）也会互相覆盖
+func checkMessageNames(tables *config.TableCache, fss []string, protoVersionData map[string]ProtoVersion, report *BuildReport.Report) error {
+	sheets := map[string][]string{}
 
-		if _, err := os.Stat(itselfProto); err == nil {
-			errRemove := os.Remove(itselfProto)
-			if errRemove != nil {
-				return errors.Errorf("删除proto文件失败 %v", errRemove)
-			}
-		}
+	// key=表格名（不带后缀） value=表格文件
+	files := map[string]string{}
+	hasConflict := false
 
-		fileProto, errNewProto := os.OpenFile(itselfProto, os.O_CREATE, 0777)
-		fileProto.Close()
+	for _, fName := range fss {
 
-		if errNewProto != nil {
-			return errors.Errorf("创建proto文件失败 %v", errNewProto)
+		if !config.IsTableFile(fName) {
+			continue
 		}
 
-		//消息头部
-		_, err := builder.WriteString("syntax = \"proto3\";\n\npackage conf;\n\noption go_package=\"" + ProtoPath + ";conf\";")
+		filenameOnly := strings.TrimSuffix(fName, filepath.Ext(fName))
 
-		if err != nil {
-			return errors.Errorf("GenItselfProtoStr builder.WriteString Proto Head Err:%v", err)
+		if other, exist := files[filenameOnly]; exist {
+			report.Error(fName, "", 0, 0, "和表格%v同名，生成的proto和数据库会互相覆盖，修改其中一个的文件名", other)
+			hasConflict = true
+			continue
 		}
+		files[filenameOnly] = fName
 
-		//写入proto文件
-		errGenProto := GenProtoTomessage(path, sheetName, memberMap, &builder, protoIdGen)
-
-		if errGenProto != nil {
-			return errors.Errorf("GenProtoTomessage 生成各自 proto失败 %v", errGenProto)
+		data, errRead := tables.Data(fName)
+		if errRead != nil {
+			return errRead
 		}
 
-		errWrite := os.WriteFile(ProtoPath+messageName+".proto", []byte(builder.String()), 0777)
+		if v, isOk := protoVersionData[filenameOnly]; isOk && v.ExcelMd5 == protoSourceMd5(data) && v.Sheets != nil {
+			sheets[filenameOnly] = v.Sheets
+			continue
+		}
 
-		if errWrite != nil {
-			return errors.Errorf("写入各自proto文件失败 %v", errWrite)
+		sheetNames, errList := ListSheets(tables, fName)
+		if errList != nil {
+			//genProtoByTable 中会报错
+			continue
 		}
 
-		if csPath != "" {
-			errRun := conf_tool.RunCommand("protoc", "--csharp_out="+csPath, ProtoPath+messageName+".proto")
+		sheets[filenameOnly] = sheetNames
+	}
 
-			if errRun != nil {
-				return errors.Errorf("本次版本生成cs文件失败 %v", errRun)
-			}
+	conflicts := ProtoIDGen.MessageNameConflicts(sheets)
+	if len(conflicts) == 0 && !hasConflict {
+		return nil
+	}
+
+	for name, conflictFiles := range conflicts {
+		for _, file := range conflictFiles {
+			report.Error(files[file], "", 0, 0, "生成的proto消息名%v和其他表格重复：%v，修改表格名或页签名，或者按表格分package", name, strings.Join(conflictFiles, "，"))
 		}
+	}
+
+	return ErrMessageNameConflict
+}
 
-		protoNameMap[messageName+".proto"] = struct{}{}
+// ListSheets list页签中存在的页签名
+func ListSheets(tables *config.TableCache, fName string) ([]string, error) {
+	table, errOpen := tables.Table(fName)
+	if errOpen != nil {
+		return nil, errOpen
 	}
 
-	protoVersionData[filenameOnly] = ProtoVersion{
-		ExcelMd5:  md5.String(data),
-		ProtoName: protoNameMap,
+	var sheetNames []string
+	for _, sheetRow := range table.ListRows {
+		if len(sheetRow) < 1 || table.Sheet(sheetRow[0]) == nil {
+			continue
+		}
+		sheetNames = append(sheetNames, sheetRow[0])
 	}
 
-	return nil
+	return sheetNames, nil
 }
 
 func GenItselfProtoStr(protoPath string, messageName string, builder *strings.Builder) error {
@@ -415,17 +509,29 @@ func GenItselfProtoStr(protoPath string, messageName string, builder *strings.Bu
 	return nil
 }
 
+// fieldIdType 字段类型在ProtoID key中的写法
+// optional 不参与ID的key，保证可空和非可空切换时ID不变
+func fieldIdType(memberType string) string {
+	strColType := strings.TrimPrefix(memberType, "optional ")
+	if strings.HasPrefix(strColType, "repeated") {
+		strColType = strings.Trim(strings.TrimSpace(strColType), "repeated") + "_array"
+	}
+
+	return strColType
+}
+
 type ProtoSort struct {
 	ProtoId    int
 	memberName string
 	memberType string
 }
 
-func GenProtoTomessage(path string, sheetName string, memberMap map[string]string, builder *strings.Builder, protoIdGen *ProtoIDGen.ProtoIdGen) error {
+// GenProtoTomessage fName 是相对配置目录的表格路径
+func GenProtoTomessage(fName string, sheetName string, memberMap map[string]string, builder *strings.Builder, protoIdGen *ProtoIDGen.ProtoIdGen) error {
 	//获取文件名带后缀
-	filenameWithSuffix := filepath.Base(path)
+	filenameWithSuffix := fName
 	//获取文件后缀
-	fileSuffix := filepath.Ext(path)
+	fileSuffix := filepath.Ext(fName)
 	//获取文件名
 	filenameOnly := strings.TrimSuffix(filenameWithSuffix, fileSuffix)
 
@@ -433,32 +539,65 @@ func GenProtoTomessage(path string, sheetName string, memberMap map[string]strin
 
 	vecSort := make([]ProtoSort, 0, len(memberMap))
 
-	for k, v := range memberMap {
+	// key=proto字段名 value=表格标题，不同标题转换后可能重名
+	nameMap := map[string]string{}
+
+	//带点的标题生成嵌套消息
+	nested := newNestedNode("")
+
+	//按标题顺序分配新字段的ProtoID，同样的表格每次生成的ID一致
+	titles := make([]string, 0, len(memberMap))
+	for k := range memberMap {
+		titles = append(titles, k)
+	}
+	sort.Strings(titles)
 
-		strColType := v
-		if strings.HasPrefix(strColType, "repeated") {
-			strColType = strings.Trim(strings.TrimSpace(strColType), "repeated") + "_array"
+	for _, k := range titles {
+		v := memberMap[k]
+		if ProtoIDGen.NestedMessage && strings.Contains(k, ".") {
+			if errNested := nested.add(k, v); errNested != nil {
+				return errors.Errorf("页签：%v %v", sheetName, errNested)
+			}
+			continue
 		}
 
-		fieldName := sheetName + ProtoIDGen.KeySep + k + ProtoIDGen.KeySep + strColType
+		memberName := config.Naming.Name(config.OutputProto, k)
+		if other, exist := nameMap[memberName]; exist {
+			return errors.Errorf("页签：%v 标题[%v]和[%v]转换成proto字段名后重复：%v", sheetName, other, k, memberName)
+		}
+		nameMap[memberName] = k
+
+		fieldName := sheetName + ProtoIDGen.KeySep + k + ProtoIDGen.KeySep + fieldIdType(v)
 
 		vecSort = append(vecSort, ProtoSort{
 			ProtoId:    protoIdGen.GetTypeFieldId(filenameOnly, fieldName),
-			memberName: k,
+			memberName: memberName,
 			memberType: v,
 		})
 	}
 
-	sort.Slice(vecSort, func(i, j int) bool {
-		return vecSort[i].ProtoId < vecSort[j].ProtoId
-	})
-
 	_, errProtoStr := builder.WriteString("\nmessage  " + messageName + "   {\n\n")
 
 	if errProtoStr != nil {
 		return errors.Errorf("builder.WriteString Proto Head Err:%v", errProtoStr)
 	}
 
+	nestedFields, errNested := nested.writeNested(filenameOnly, sheetName, "    ", builder, protoIdGen)
+	if errNested != nil {
+		return errNested
+	}
+
+	for _, v := range nestedFields {
+		if other, exist := nameMap[v.memberName]; exist {
+			return errors.Errorf("页签：%v 标题[%v]和嵌套字段重复：%v", sheetName, other, v.memberName)
+		}
+		vecSort = append(vecSort, v)
+	}
+
+	sort.Slice(vecSort, func(i, j int) bool {
+		return vecSort[i].ProtoId < vecSort[j].ProtoId
+	})
+
 	for _, v := range vecSort {
 
 		writeStr := "    " + v.memberType + "   " + v.memberName + " = " + strconv.Itoa(v.ProtoId) + ";\n\n"
@@ -478,42 +617,3 @@ func GenProtoTomessage(path string, sheetName string, memberMap map[string]strin
 
 	return nil
 }
-
-func getProtoType(titleType string) string {
-	protoType := "string"
-
-	if titleType == "bool" {
-		protoType = "bool"
-	}
-
-	if titleType == "float" {
-		protoType = "float"
-	}
-
-	if titleType == "int" {
-		protoType = "int32"
-	}
-
-	typeArray := strings.Split(titleType, "_")
-
-	if len(typeArray) == 2 && typeArray[1] == "list" {
-
-		repeatedType := "string"
-
-		if typeArray[0] == "bool" {
-			repeatedType = "bool"
-		}
-
-		if typeArray[0] == "float" {
-			repeatedType = "float"
-		}
-
-		if typeArray[0] == "int" {
-			repeatedType = "int32"
-		}
-
-		protoType = "repeated " + repeatedType
-	}
-
-	return protoType
-}
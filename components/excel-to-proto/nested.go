@@ -0,0 +1,183 @@
+package excel_to_proto
+
+import (
+	"Tool-Library/components/ProtoIDGen"
+	"Tool-Library/shared/config"
+	"github.com/pkg/errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// nestedNode 标题中用点分隔的嵌套字段，数字是对象数组的下标，例如 pos.x、drops.0.id
+type nestedNode struct {
+	// 去掉数组下标的标题路径，例如 drops.id，生成ProtoID的key
+	path string
+
+	// 叶子节点的proto类型
+	memberType string
+
+	// 子字段前面带数组下标（drops.0.id）生成repeated消息，不带下标（pos.x）生成单个消息，不能混用
+	indexed bool
+	plain   bool
+
+	children map[string]*nestedNode
+}
+
+func newNestedNode(path string) *nestedNode {
+	return &nestedNode{path: path, children: map[string]*nestedNode{}}
+}
+
+func isIndexSegment(segment string) bool {
+	_, err := strconv.Atoi(segment)
+	return err == nil
+}
+
+// add 加入一个带点的标题
+func (n *nestedNode) add(title string, memberType string) error {
+	segments := strings.Split(title, ".")
+
+	cur := n
+	afterIndex := false
+
+	for i, segment := range segments {
+		segment = strings.TrimSpace(segment)
+
+		if segment == "" {
+			return errors.Errorf("标题[%v]格式不对，点前后不能为空", title)
+		}
+
+		if isIndexSegment(segment) {
+			if cur == n || afterIndex {
+				return errors.Errorf("标题[%v]格式不对，数组下标前面需要字段名", title)
+			}
+
+			if i == len(segments)-1 {
+				return errors.Errorf("标题[%v]格式不对，数组下标后面需要字段名", title)
+			}
+
+			afterIndex = true
+			continue
+		}
+
+		if cur != n {
+			if afterIndex {
+				cur.indexed = true
+			} else {
+				cur.plain = true
+			}
+
+			if cur.indexed && cur.plain {
+				return errors.Errorf("标题[%v]和其他标题冲突，%v 不能同时有带数组下标和不带下标的字段", title, cur.path)
+			}
+		}
+		afterIndex = false
+
+		child := cur.children[segment]
+		if child == nil {
+			path := segment
+			if cur != n {
+				path = cur.path + "." + segment
+			}
+
+			child = newNestedNode(path)
+			cur.children[segment] = child
+		}
+
+		if i == len(segments)-1 {
+			if len(child.children) > 0 {
+				return errors.Errorf("标题[%v]和其他标题冲突，%v 已经是嵌套消息", title, child.path)
+			}
+
+			if child.memberType != "" && child.memberType != memberType {
+				return errors.Errorf("标题[%v]类型不一致：%v，%v", title, child.memberType, memberType)
+			}
+
+			child.memberType = memberType
+		} else if child.memberType != "" {
+			return errors.Errorf("标题[%v]和其他标题冲突，%v 已经是普通字段", title, child.path)
+		}
+
+		cur = child
+	}
+
+	return nil
+}
+
+// nestedTypeName 嵌套消息的类型名，和字段名相同时加后缀
+func nestedTypeName(segment string, memberName string) string {
+	typeName := config.SanitizeIdentifier(config.ConvertCase(config.CasePascal, segment), true)
+	if typeName == memberName {
+		typeName += "Msg"
+	}
+
+	return typeName
+}
+
+// writeNested 子节点中的嵌套消息定义写入builder，返回子节点对应的字段
+func (n *nestedNode) writeNested(filenameOnly string, sheetName string, indent string, builder *strings.Builder, protoIdGen *ProtoIDGen.ProtoIdGen) ([]ProtoSort, error) {
+	var segments []string
+	for segment := range n.children {
+		segments = append(segments, segment)
+	}
+	sort.Strings(segments)
+
+	var fields []ProtoSort
+
+	// key=proto字段名 value=标题路径
+	nameMap := map[string]string{}
+
+	for _, segment := range segments {
+		child := n.children[segment]
+
+		memberName := config.Naming.Name(config.OutputProto, segment)
+		if other, exist := nameMap[memberName]; exist {
+			return nil, errors.Errorf("页签：%v 标题[%v]和[%v]转换成proto字段名后重复：%v", sheetName, other, child.path, memberName)
+		}
+		nameMap[memberName] = child.path
+
+		memberType := child.memberType
+
+		if len(child.children) > 0 {
+			typeName := nestedTypeName(segment, memberName)
+
+			builder.WriteString(indent + "message " + typeName + " {\n\n")
+
+			childFields, err := child.writeNested(filenameOnly, sheetName, indent+"    ", builder, protoIdGen)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, v := range childFields {
+				builder.WriteString(indent + "    " + v.memberType + "   " + v.memberName + " = " + strconv.Itoa(v.ProtoId) + ";\n\n")
+			}
+
+			builder.WriteString(indent + "}\n\n")
+
+			memberType = typeName
+			if child.indexed {
+				memberType = "repeated " + typeName
+			}
+		}
+
+		idType := fieldIdType(memberType)
+		if len(child.children) > 0 {
+			idType = "message"
+			if child.indexed {
+				idType += "_array"
+			}
+		}
+
+		fields = append(fields, ProtoSort{
+			ProtoId:    protoIdGen.GetTypeFieldId(filenameOnly, sheetName+ProtoIDGen.KeySep+child.path+ProtoIDGen.KeySep+idType),
+			memberName: memberName,
+			memberType: memberType,
+		})
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].ProtoId < fields[j].ProtoId
+	})
+
+	return fields, nil
+}
@@ -0,0 +1,82 @@
+package excel_to_proto
+
+import (
+	"Tool-Library/components/BuildCache"
+	"Tool-Library/components/ProtoIDGen"
+	conf_tool "Tool-Library/components/conf-tool"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"sort"
+)
+
+// LoadProtoVersion 读取ProtoVersion.json，文件不存在时返回空记录
+func LoadProtoVersion(ProtoPath string) (map[string]ProtoVersion, error) {
+	protoVersionData := map[string]ProtoVersion{}
+
+	data, errRead := os.ReadFile(ProtoPath + ProtoVersionName)
+	if os.IsNotExist(errRead) || (errRead == nil && len(data) == 0) {
+		return protoVersionData, nil
+	}
+
+	if errRead != nil {
+		return nil, errors.Errorf("读取ProtoVersion失败 %v", errRead)
+	}
+
+	if errJson := json.Unmarshal(data, &protoVersionData); errJson != nil {
+		return nil, errors.Errorf("解析ProtoVersion失败 %v", errJson)
+	}
+
+	return protoVersionData, nil
+}
+
+// ValidateProtoVersion 删除proto文件不存在或者内容和记录不一致的页签，这些页签下次转表时重新写入，返回删除的记录和原因
+func ValidateProtoVersion(ProtoPath string, protoVersionData map[string]ProtoVersion) []string {
+	filenames := make([]string, 0, len(protoVersionData))
+	for filenameOnly := range protoVersionData {
+		filenames = append(filenames, filenameOnly)
+	}
+	sort.Strings(filenames)
+
+	var invalid []string
+	for _, filenameOnly := range filenames {
+		version := protoVersionData[filenameOnly]
+
+		sheetNames := make([]string, 0, len(version.SheetKeys))
+		for sheetName := range version.SheetKeys {
+			sheetNames = append(sheetNames, sheetName)
+		}
+		sort.Strings(sheetNames)
+
+		for _, sheetName := range sheetNames {
+			protoFileName := ProtoIDGen.GetProtoFileName(filenameOnly, sheetName)
+
+			reason := ""
+			if source, errRead := os.ReadFile(ProtoPath + protoFileName); os.IsNotExist(errRead) {
+				reason = "proto文件不存在"
+			} else if errRead != nil {
+				reason = "读取proto文件失败 " + errRead.Error()
+			} else if BuildCache.Digest(string(source)) != version.SheetKeys[sheetName].Schema {
+				reason = "proto文件内容不一致"
+			}
+
+			if reason != "" {
+				delete(version.SheetKeys, sheetName)
+				delete(version.ProtoName, protoFileName)
+				invalid = append(invalid, filenameOnly+ProtoIDGen.KeySep+sheetName+" "+ProtoPath+protoFileName+"："+reason)
+			}
+		}
+	}
+
+	return invalid
+}
+
+// SaveProtoVersion 原子写入ProtoVersion.json
+func SaveProtoVersion(ProtoPath string, protoVersionData map[string]ProtoVersion) error {
+	jsonBytes, errJson := json.Marshal(protoVersionData)
+	if errJson != nil {
+		return errors.Errorf("序列化protoVersionData报错 json.Marshal: %v", errJson)
+	}
+
+	return conf_tool.WriteFile(ProtoPath+ProtoVersionName, jsonBytes)
+}
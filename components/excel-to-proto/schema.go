@@ -0,0 +1,99 @@
+package excel_to_proto
+
+import (
+	"Tool-Library/components/BuildCache"
+	"Tool-Library/components/BuildReport"
+	"Tool-Library/components/ProtoIDGen"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/pkg/errors"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Schemas 转表时在内存中生成的消息描述，生成数据库时直接使用，不需要再读取proto文件，并发安全
+// key=proto文件名，见 ProtoIDGen.GetProtoFileName
+type Schemas struct {
+	protoPath  string
+	protoIdGen *ProtoIDGen.ProtoIdGen
+
+	mux      sync.Mutex
+	messages map[string]*desc.MessageDescriptor
+
+	// proto内容的md5，作为页签缓存key的一部分
+	digests map[string]string
+}
+
+func NewSchemas(protoPath string, protoIdGen *ProtoIDGen.ProtoIdGen) *Schemas {
+	return &Schemas{
+		protoPath:  protoPath,
+		protoIdGen: protoIdGen,
+		messages:   map[string]*desc.MessageDescriptor{},
+		digests:    map[string]string{},
+	}
+}
+
+// add 解析页签生成的proto内容，google/protobuf 的import使用protoparse内置的文件
+func (s *Schemas) add(filenameOnly string, sheetName string, source string) (*desc.MessageDescriptor, error) {
+	protoFileName := ProtoIDGen.GetProtoFileName(filenameOnly, sheetName)
+
+	parser := protoparse.Parser{
+		Accessor: protoparse.FileContentsFromMap(map[string]string{protoFileName: source}),
+	}
+
+	fileDescs, err := parser.ParseFiles(protoFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	messageName := ProtoIDGen.GetMessageName(filenameOnly, sheetName)
+
+	msgDesc := fileDescs[0].FindMessage(fileDescs[0].GetPackage() + "." + messageName)
+	if msgDesc == nil {
+		return nil, errors.Errorf("Proto数据中没有找到messageName 消息名称名称：%v", messageName)
+	}
+
+	s.mux.Lock()
+	s.messages[protoFileName] = msgDesc
+	s.digests[protoFileName] = BuildCache.Digest(source)
+	s.mux.Unlock()
+
+	return msgDesc, nil
+}
+
+// Message 页签对应的消息描述，表格没有变化、本次没有生成proto时按页签内容重新生成（ProtoID不变，和proto文件一致）
+// rows至少有 starReadLine 行
+func (s *Schemas) Message(fName string, sheetName string, rows [][]string) (*desc.MessageDescriptor, error) {
+	filenameOnly := strings.TrimSuffix(fName, filepath.Ext(fName))
+
+	s.mux.Lock()
+	msgDesc := s.messages[ProtoIDGen.GetProtoFileName(filenameOnly, sheetName)]
+	s.mux.Unlock()
+
+	if msgDesc != nil {
+		return msgDesc, nil
+	}
+
+	//警告在生成proto时已经记录过
+	source, err := SheetProto(fName, sheetName, rows, s.protoPath, s.protoIdGen, BuildReport.New())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.add(filenameOnly, sheetName, source)
+}
+
+// Digest 页签proto内容的md5，和 Message 一样没有生成过时按页签内容重新生成
+func (s *Schemas) Digest(fName string, sheetName string, rows [][]string) (string, error) {
+	if _, err := s.Message(fName, sheetName, rows); err != nil {
+		return "", err
+	}
+
+	filenameOnly := strings.TrimSuffix(fName, filepath.Ext(fName))
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.digests[ProtoIDGen.GetProtoFileName(filenameOnly, sheetName)], nil
+}
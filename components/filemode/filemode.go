@@ -49,6 +49,10 @@ func MkdirAll(path string, mode os.FileMode) error {
 	elements := strings.Split(path, "/")
 
 	cumulativePath := ""
+	//绝对路径从根目录开始，否则会在当前目录下创建
+	if strings.HasPrefix(path, "/") {
+		cumulativePath = "/"
+	}
 
 	for _, e := range elements {
 
@@ -76,3 +80,40 @@ func MkdirAll(path string, mode os.FileMode) error {
 
 	return nil
 }
+
+// WriteFileAtomic 先写到同目录下的临时文件再改名，进程中途退出时不会留下写了一半的文件
+func WriteFileAtomic(path string, data []byte, mode os.FileMode) error {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+
+	if err == nil {
+		err = os.Chmod(tmpPath, mode)
+	}
+
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tealeg/xlsx"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CellOption 单元格读取方式，服务器tsv和客户端db使用同一份配置，保证同一个单元格读出来的内容一致
+type CellOption struct {
+	// 去掉首尾空白（包括换行）
+	TrimSpace bool
+
+	// 用2个空格替换1个tab（tab是tsv的分隔符）
+	ReplaceTab bool
+
+	// 去掉双引号（tsv解析时会把双引号当成换行的包裹符）
+	RemoveQuote bool
+
+	// 合并单元格的值填充到整个合并区域，否则只有左上角有值
+	FillMerged bool
+
+	// 小数按Excel显示的15位有效数字输出，去掉公式结果的浮点误差（0.30000000000000004），否则输出原始数值
+	ExcelPrecision bool
+}
+
+// DefaultCellOption 默认和之前的服务器tsv一致，合并单元格和15位有效数字需要单独开启
+var DefaultCellOption = &CellOption{
+	ReplaceTab:  true,
+	RemoveQuote: true,
+}
+
+// CellString 读取单元格的值
+// 数字统一按数值输出，不受百分比、千分位、日期等单元格格式影响；公式使用Excel保存的计算结果
+func CellString(cell *xlsx.Cell, opt *CellOption) (string, error) {
+	if cell == nil {
+		return "", nil
+	}
+
+	if opt == nil {
+		opt = DefaultCellOption
+	}
+
+	text := cell.Value
+
+	if text != "" && cell.Type() == xlsx.CellTypeNumeric {
+		f, err := cell.Float()
+		if err != nil {
+			return "", err
+		}
+
+		text = opt.formatNumber(f)
+	}
+
+	return opt.normalize(text), nil
+}
+
+func (opt *CellOption) formatNumber(f float64) string {
+	if opt.ExcelPrecision {
+		return FormatNumber(f)
+	}
+
+	return FormatRawNumber(f)
+}
+
+func (opt *CellOption) normalize(text string) string {
+	if opt.ReplaceTab {
+		// 用2个空格替换1个tab
+		text = strings.ReplaceAll(text, "\t", "  ")
+	}
+
+	if opt.RemoveQuote {
+		// 去掉双引号
+		text = strings.ReplaceAll(text, "\"", "")
+	}
+
+	if opt.TrimSpace {
+		text = strings.TrimSpace(text)
+	}
+
+	return text
+}
+
+// FormatNumber 整数不带小数点，小数去掉Excel的浮点误差（Excel只保留15位有效数字）
+func FormatNumber(f float64) string {
+	if excel, err := strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64); err == nil {
+		f = excel
+	}
+
+	if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// FormatRawNumber 整数不带小数点，小数按原始数值输出
+func FormatRawNumber(f float64) string {
+	if f == float64(int64(f)) {
+		return strconv.FormatInt(int64(f), 10)
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// ReadSheet 读取整个页签的单元格
+func ReadSheet(sheet *xlsx.Sheet, opt *CellOption) ([][]string, error) {
+	if opt == nil {
+		opt = DefaultCellOption
+	}
+
+	rows := make([][]string, len(sheet.Rows))
+
+	for i, row := range sheet.Rows {
+		if row == nil {
+			continue
+		}
+
+		rows[i] = make([]string, len(row.Cells))
+
+		for j, cell := range row.Cells {
+			text, err := CellString(cell, opt)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s 解析失败 %d行-%d列 %v", sheet.Name, i+1, j+1, cell)
+			}
+
+			rows[i][j] = text
+		}
+	}
+
+	if opt.FillMerged {
+		for i, row := range sheet.Rows {
+			if row == nil {
+				continue
+			}
+
+			for j, cell := range row.Cells {
+				if cell == nil || (cell.HMerge <= 0 && cell.VMerge <= 0) {
+					continue
+				}
+
+				for r := i; r <= i+cell.VMerge && r < len(rows); r++ {
+					for len(rows[r]) <= j+cell.HMerge {
+						rows[r] = append(rows[r], "")
+					}
+
+					for c := j; c <= j+cell.HMerge; c++ {
+						rows[r][c] = rows[i][j]
+					}
+				}
+			}
+		}
+	}
+
+	return rows, nil
+}
+
+// CellRef 单元格的A1坐标，row col 从0开始，例如 (16, 2) -> C17
+func CellRef(row, col int) string {
+	name := ""
+	for c := col + 1; c > 0; c = (c - 1) / 26 {
+		name = string(rune('A'+(c-1)%26)) + name
+	}
+
+	return name + strconv.Itoa(row+1)
+}
+
+// SheetCell 越界返回空字符串
+func SheetCell(rows [][]string, row, col int) string {
+	if row < 0 || row >= len(rows) || col < 0 || col >= len(rows[row]) {
+		return ""
+	}
+
+	return rows[row][col]
+}
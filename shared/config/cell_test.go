@@ -0,0 +1,68 @@
+package config
+
+import (
+	"github.com/tealeg/xlsx"
+	"reflect"
+	"testing"
+)
+
+// 变量相加才有浮点误差，常量在编译时精确计算
+var tenth, fifth = 0.1, 0.2
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		in    float64
+		raw   string
+		excel string
+	}{
+		{in: 3, raw: "3", excel: "3"},
+		{in: -12, raw: "-12", excel: "-12"},
+		{in: 1.5, raw: "1.5", excel: "1.5"},
+		{in: tenth + fifth, raw: "0.30000000000000004", excel: "0.3"},
+		{in: 1e20, raw: "100000000000000000000", excel: "100000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatRawNumber(tt.in); got != tt.raw {
+			t.Errorf("FormatRawNumber(%v) = %s, want %s", tt.in, got, tt.raw)
+		}
+
+		if got := FormatNumber(tt.in); got != tt.excel {
+			t.Errorf("FormatNumber(%v) = %s, want %s", tt.in, got, tt.excel)
+		}
+	}
+}
+
+func TestReadSheet(t *testing.T) {
+	sheet, err := xlsx.NewFile().AddSheet("Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row := sheet.AddRow()
+	merged := row.AddCell()
+	merged.SetString("a\t\"b\"")
+	merged.Merge(1, 0)
+	row.AddCell()
+	row.AddCell().SetFloat(tenth + fifth)
+
+	tests := []struct {
+		name string
+		opt  *CellOption
+		want []string
+	}{
+		{name: "默认", opt: DefaultCellOption, want: []string{"a  b", "", "0.30000000000000004"}},
+		{name: "合并单元格和15位有效数字", opt: &CellOption{ReplaceTab: true, RemoveQuote: true, FillMerged: true, ExcelPrecision: true}, want: []string{"a  b", "a  b", "0.3"}},
+	}
+
+	for _, tt := range tests {
+		rows, err := ReadSheet(sheet, tt.opt)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if !reflect.DeepEqual(rows[0], tt.want) {
+			t.Errorf("%s: ReadSheet = %q, want %q", tt.name, rows[0], tt.want)
+		}
+	}
+}
@@ -3,19 +3,15 @@ package config
 import (
 	"bytes"
 	"embed"
-	"fmt"
 	"github.com/axgle/mahonia"
-	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
-	"github.com/tealeg/xlsx"
 	unicode16 "golang.org/x/text/encoding/unicode"
 	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"unicode"
@@ -177,7 +173,7 @@ func NewEmbedGameObjects(dir string) (*GameObjects, error) {
 			return errors.Wrapf(err1, "read config fail, %s", path0)
 		}
 
-		if strings.HasSuffix(dp, ".xlsx") {
+		if IsWorkbook(dp) {
 			result, err := parseExcelFile(dp, data)
 			if err != nil {
 				return errors.Wrapf(err, "解析excel文件出错: %s", dp)
@@ -285,7 +281,7 @@ func NewConfigGameObjects(dir string) (*GameObjects, error) {
 			return errors.Wrapf(err1, "read config fail, %s", path0)
 		}
 
-		if strings.HasSuffix(dp, ".xlsx") {
+		if IsWorkbook(dp) {
 			result, err := parseExcelFile(dp, data)
 			if err != nil {
 				return errors.Wrapf(err, "解析excel文件出错: %s", dp)
@@ -357,45 +353,25 @@ func NewConfigGameObjects(dir string) (*GameObjects, error) {
 
 func parseExcelFile(path string, data []byte) (result map[string][]byte, err error) {
 	// excel
-	xlFile, err := xlsx.OpenBinary(data)
+	xlFile, err := OpenWorkbook(path, data)
 	if err != nil {
 		return
 	}
 
 	result = map[string][]byte{}
 
-	for name, sheet := range xlFile.Sheet {
+	names := xlFile.SheetNames()
+	for _, name := range names {
 		bytesBuffer := bytes.NewBuffer(nil)
 
-		for i, row := range sheet.Rows {
-			r := i + 1
-			for i, cell := range row.Cells {
-				c := i + 1
-
-				text := cell.Value
-
-				if text != "" {
-					switch cell.Type() {
-					case xlsx.CellTypeString:
-						text = cell.String()
-					case xlsx.CellTypeStringFormula:
-						text, err = cell.FormattedValue()
-					case xlsx.CellTypeNumeric:
-						var result float64
-						result, err = cell.Float()
-						if result == float64(int64(result)) {
-							text = fmt.Sprintf("%d", int64(result))
-						} else {
-							text = strconv.FormatFloat(result, 'f', -1, 64)
-						}
-					}
-				}
-
-				if err != nil {
-					err = errors.Wrapf(err, "%s 解析失败 %d行-%d列 %v", sheet.Name, r, c, cell)
-					return
-				}
+		var rows [][]string
+		rows, err = xlFile.Sheet(name).Rows()
+		if err != nil {
+			return
+		}
 
+		for _, row := range rows {
+			for _, text := range row {
 				bytesBuffer.WriteString(text)
 				bytesBuffer.WriteString("\t")
 			}
@@ -406,7 +382,7 @@ func parseExcelFile(path string, data []byte) (result map[string][]byte, err err
 		d := bytesBuffer.Bytes()
 		result[path+":"+name] = d
 
-		if len(xlFile.Sheet) == 1 {
+		if len(names) == 1 {
 			result[path] = d
 		}
 	}
@@ -414,74 +390,13 @@ func parseExcelFile(path string, data []byte) (result map[string][]byte, err err
 	return
 }
 
+// parseJsonFile 嵌套对象展开成用点连接的列，例如 pos.x，对象数组按下标展开，例如 drops.0.id
+// 基础类型数组和原来一样展开成多个同名列
 func parseJsonFile(path string, data []byte) (result map[string][]byte, err error) {
 
-	root := jsoniter.Get(data)
-	if root.ValueType() != jsoniter.ArrayValue {
-		return nil, errors.Errorf("%s 文件格式不对，最外层必须是数组", path)
-	}
-
-	type Field struct {
-		name         string
-		nameLowSnake string
-		valueType    jsoniter.ValueType
-		n            int // > 0 表示数组长度
-	}
-
-	var fields []*Field
-	fieldMap := make(map[string]*Field)
-
-	// 先遍历整个Json字符串，把表头定义搞出来
-	for i := 0; i < root.Size(); i++ {
-		val := root.Get(i)
-		if val.ValueType() != jsoniter.ObjectValue {
-			return nil, errors.Errorf("%s 文件格式不对，第%v行数据第二层必须是Object, t: %v", path, i+1, val.ValueType())
-		}
-
-		for _, k := range val.Keys() {
-			data := val.Get(k)
-			if data.ValueType() == jsoniter.ObjectValue {
-				return nil, errors.Errorf("%s 文件格式不对，第%v行数据%s字段第三层不能是Object（不能用嵌套格式）", path, i+1, k)
-				//continue
-			}
-
-			if data.ValueType() == jsoniter.InvalidValue {
-				return nil, errors.Errorf("%s 文件格式不对，第%v行数据%s字段第三层解析不出来", path, i+1, k)
-			}
-
-			f := fieldMap[k]
-			if f == nil {
-				f = &Field{}
-				f.name = k
-				f.nameLowSnake = toLowerSnakeCase(k)
-				f.valueType = data.ValueType()
-				f.n = data.Size()
-
-				fieldMap[k] = f
-				fields = append(fields, f)
-			} else {
-				if f.valueType != data.ValueType() {
-					return nil, errors.Errorf("%s 文件格式不对，第%v行数据%s字段, 前后的数据类型对不上，prev: %v cur: %v", path, i+1, k, f.valueType, data.ValueType())
-				}
-
-				if data.Size() > 0 {
-					for i := 0; i < data.Size(); i++ {
-						itemType := data.Get(i).ValueType()
-						if itemType == jsoniter.ObjectValue {
-							return nil, errors.Errorf("%s 文件格式不对，第%v行数据%s字段数组元素不能是Object（不能用嵌套格式）", path, i+1, k)
-						}
-
-						if itemType == jsoniter.InvalidValue {
-							return nil, errors.Errorf("%s 文件格式不对，第%v行数据%s字段数组元素解析不出来", path, i+1, k)
-						}
-					}
-
-					if f.n < data.Size() {
-						f.n = data.Size()
-					}
-				}
-			}
-		}
+	t, err := flattenJson(path, data)
+	if err != nil {
+		return nil, err
 	}
 
 	b := bytes.Buffer{}
@@ -507,43 +422,19 @@ func parseJsonFile(path string, data []byte) (result map[string][]byte, err erro
 		isFirst = true
 	}
 
+	heads := t.heads()
 	for i := 0; i < 2; i++ {
 		// 头2行是head，第一行描述，第二行字段名
-		for _, f := range fields {
-			if f.n > 0 {
-				for i := 0; i < f.n; i++ {
-					writeField(f.nameLowSnake)
-				}
-			} else {
-				writeField(f.nameLowSnake)
-			}
+		for _, head := range heads {
+			writeField(head)
 		}
 		writeNewLine()
 	}
 
 	// 这行开始写入数据
-	for i := 0; i < root.Size(); i++ {
-		val := root.Get(i)
-		for _, f := range fields {
-			if f.n > 0 {
-				array := val.Get(f.name)
-
-				for i := 0; i < array.Size(); i++ {
-					writeField(array.Get(i).ToString())
-				}
-
-				for i := array.Size(); i < f.n; i++ {
-					writeField("")
-				}
-
-			} else {
-				// 非数组，直接写入string即可
-				if field := val.Get(f.name); field != nil && field.ValueType() != jsoniter.NilValue {
-					writeField(field.ToString())
-				} else {
-					writeField("")
-				}
-			}
+	for _, row := range t.rows {
+		for _, cell := range t.cells(row) {
+			writeField(cell)
 		}
 		writeNewLine()
 	}
@@ -583,23 +474,23 @@ func NewKeyValueGameObjects(name, content string, kv ...string) (*GameObjects, e
 
 	n := len(kv)
 	if n%2 != 0 {
-		return nil, errors.Errorf("len(kv) %2 != 0, kv must be pair, len: %s, %s", len(kv), kv)
+		return nil, errors.Errorf("len(kv) %%2 != 0, kv must be pair, len: %d, %s", len(kv), kv)
 	}
 
 	n = n / 2
 	for i := 0; i < n; i++ {
 		k := kv[i*2]
 		if k == "" {
-			return nil, errors.Errorf("key empty, index: %s", i*2)
+			return nil, errors.Errorf("key empty, index: %d", i*2)
 		}
 
 		v := kv[i*2+1]
 		if v == "" {
-			return nil, errors.Errorf("value empty, index: %s", i*2+1)
+			return nil, errors.Errorf("value empty, index: %d", i*2+1)
 		}
 
 		if _, ok := gos.dataMap[k]; ok {
-			return nil, errors.Errorf("duplicate key, index: %s, key: %s", i*2, k)
+			return nil, errors.Errorf("duplicate key, index: %d, key: %s", i*2, k)
 		}
 
 		gos.dataMap[k] = []byte(v)
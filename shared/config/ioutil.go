@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -83,6 +84,48 @@ func ReadDir(dir string) ([]fs.FileInfo, error) {
 	return fs, nil
 }
 
+// WalkDir 递归读取目录下的所有文件，返回相对dir的路径（/分隔，已排序），跳过.开头的目录（.git .svn）
+func WalkDir(dir string) ([]string, error) {
+	dir = strings.TrimSuffix(dir, "/")
+
+	var files []string
+
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		fs, err := ReadDir(dir + "/" + rel)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range fs {
+			name := rel + f.Name()
+
+			if f.IsDir() {
+				if strings.HasPrefix(f.Name(), ".") {
+					continue
+				}
+
+				if err := walk(name + "/"); err != nil {
+					return err
+				}
+				continue
+			}
+
+			files = append(files, name)
+		}
+
+		return nil
+	}
+
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
 func doReadDir(dir string) ([]fs.FileInfo, error) {
 	if strings.HasSuffix(dir, "/") {
 		dir = dir[:len(dir)-1]
@@ -0,0 +1,260 @@
+package config
+
+import (
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"math"
+	"strconv"
+)
+
+// jsonColumn 嵌套json展开后的一列，名字用点连接，对象数组按下标展开，例如 pos.x、drops.0.id
+type jsonColumn struct {
+	name string
+
+	// 基础类型数组是值的类型
+	valueType jsoniter.ValueType
+
+	// 基础类型数组，展开成n个同名列
+	isArray bool
+	n       int
+
+	// 数字都是整数，转表时推断类型用
+	notInteger bool
+}
+
+// jsonTable 展开后的json，每行是 key=列名 value=值（数组列有多个值）
+type jsonTable struct {
+	columns   []*jsonColumn
+	columnMap map[string]*jsonColumn
+	rows      []map[string][]string
+}
+
+// flattenJson 最外层是对象数组，每个对象一行，嵌套对象和对象数组展开成多列
+func flattenJson(path string, data []byte) (*jsonTable, error) {
+	root := jsoniter.Get(data)
+	if root.ValueType() != jsoniter.ArrayValue {
+		return nil, errors.Errorf("%s 文件格式不对，最外层必须是数组", path)
+	}
+
+	t := &jsonTable{columnMap: map[string]*jsonColumn{}}
+
+	for i := 0; i < root.Size(); i++ {
+		val := root.Get(i)
+		if val.ValueType() != jsoniter.ObjectValue {
+			return nil, errors.Errorf("%s 文件格式不对，第%v行数据第二层必须是Object, t: %v", path, i+1, val.ValueType())
+		}
+
+		row := map[string][]string{}
+		if err := t.walk(path, i+1, "", val, row); err != nil {
+			return nil, err
+		}
+
+		t.rows = append(t.rows, row)
+	}
+
+	return t, nil
+}
+
+func (t *jsonTable) walk(path string, line int, prefix string, val jsoniter.Any, row map[string][]string) error {
+	for _, k := range val.Keys() {
+		item := val.Get(k)
+		name := prefix + toLowerSnakeCase(k)
+
+		switch item.ValueType() {
+		case jsoniter.InvalidValue:
+			return errors.Errorf("%s 文件格式不对，第%v行数据%s字段解析不出来", path, line, name)
+		case jsoniter.ObjectValue:
+			if err := t.walk(path, line, name+".", item, row); err != nil {
+				return err
+			}
+		case jsoniter.ArrayValue:
+			objects, scalars := 0, 0
+			for i := 0; i < item.Size(); i++ {
+				switch item.Get(i).ValueType() {
+				case jsoniter.ObjectValue:
+					objects++
+				case jsoniter.ArrayValue:
+					return errors.Errorf("%s 文件格式不对，第%v行数据%s字段数组元素不能是数组", path, line, name)
+				case jsoniter.InvalidValue:
+					return errors.Errorf("%s 文件格式不对，第%v行数据%s字段数组元素解析不出来", path, line, name)
+				case jsoniter.NilValue:
+				default:
+					scalars++
+				}
+			}
+
+			if objects > 0 && scalars > 0 {
+				return errors.Errorf("%s 文件格式不对，第%v行数据%s字段数组中不能同时有Object和基础类型", path, line, name)
+			}
+
+			// 对象数组按下标展开，例如 drops.0.id drops.1.id
+			if objects > 0 {
+				for i := 0; i < item.Size(); i++ {
+					if item.Get(i).ValueType() != jsoniter.ObjectValue {
+						continue
+					}
+
+					if err := t.walk(path, line, name+"."+strconv.Itoa(i)+".", item.Get(i), row); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			col, err := t.column(path, line, name, true)
+			if err != nil {
+				return err
+			}
+
+			var values []string
+			for i := 0; i < item.Size(); i++ {
+				v := item.Get(i)
+				if err := col.check(path, line, v); err != nil {
+					return err
+				}
+
+				if v.ValueType() == jsoniter.NilValue {
+					values = append(values, "")
+				} else {
+					values = append(values, v.ToString())
+				}
+			}
+
+			if col.n < len(values) {
+				col.n = len(values)
+			}
+
+			row[name] = values
+		default:
+			col, err := t.column(path, line, name, false)
+			if err != nil {
+				return err
+			}
+
+			if err := col.check(path, line, item); err != nil {
+				return err
+			}
+
+			if item.ValueType() != jsoniter.NilValue {
+				row[name] = []string{item.ToString()}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *jsonTable) column(path string, line int, name string, isArray bool) (*jsonColumn, error) {
+	col := t.columnMap[name]
+	if col == nil {
+		col = &jsonColumn{name: name, isArray: isArray, valueType: jsoniter.NilValue}
+		t.columnMap[name] = col
+		t.columns = append(t.columns, col)
+		return col, nil
+	}
+
+	if col.isArray != isArray {
+		return nil, errors.Errorf("%s 文件格式不对，第%v行数据%s字段, 前后的数据类型对不上，数组和非数组", path, line, name)
+	}
+
+	return col, nil
+}
+
+// check null和任何类型都兼容
+func (c *jsonColumn) check(path string, line int, v jsoniter.Any) error {
+	valueType := v.ValueType()
+	if valueType == jsoniter.NilValue {
+		return nil
+	}
+
+	if c.valueType != jsoniter.NilValue && c.valueType != valueType {
+		return errors.Errorf("%s 文件格式不对，第%v行数据%s字段, 前后的数据类型对不上，prev: %v cur: %v", path, line, c.name, c.valueType, valueType)
+	}
+	c.valueType = valueType
+
+	if valueType == jsoniter.NumberValue {
+		if f := v.ToFloat64(); f != math.Trunc(f) || f > math.MaxInt32 || f < math.MinInt32 {
+			c.notInteger = true
+		}
+	}
+
+	return nil
+}
+
+// heads 展开后的表头，基础类型数组是多个同名列
+func (t *jsonTable) heads() []string {
+	var heads []string
+	for _, col := range t.columns {
+		heads = append(heads, col.name)
+
+		for i := 1; i < col.n; i++ {
+			heads = append(heads, col.name)
+		}
+	}
+
+	return heads
+}
+
+// cells 每行展开后的单元格，和 heads 对应
+func (t *jsonTable) cells(row map[string][]string) []string {
+	var cells []string
+	for _, col := range t.columns {
+		values := row[col.name]
+
+		n := col.n
+		if n == 0 {
+			n = 1
+		}
+
+		for i := 0; i < n; i++ {
+			if i < len(values) {
+				cells = append(cells, values[i])
+			} else {
+				cells = append(cells, "")
+			}
+		}
+	}
+
+	return cells
+}
+
+// columnType 转表时按值推断的列类型，数字超出int范围或者有小数按float处理
+func (c *jsonColumn) columnType() string {
+	switch c.valueType {
+	case jsoniter.BoolValue:
+		return "bool"
+	case jsoniter.NumberValue:
+		if c.notInteger {
+			return "float"
+		}
+		return "int"
+	}
+
+	return "string"
+}
+
+// tableRows 转表用的行：描述、标题、类型、导出标记、默认值，后面是数据
+func (t *jsonTable) tableRows() [][]string {
+	heads := t.heads()
+
+	var types, exports []string
+	for _, col := range t.columns {
+		n := col.n
+		if n == 0 {
+			n = 1
+		}
+
+		for i := 0; i < n; i++ {
+			types = append(types, col.columnType())
+			exports = append(exports, "cs")
+		}
+	}
+
+	rows := [][]string{heads, heads, types, exports, make([]string, len(heads))}
+
+	for _, row := range t.rows {
+		rows = append(rows, t.cells(row))
+	}
+
+	return rows
+}
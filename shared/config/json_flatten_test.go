@@ -0,0 +1,103 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlattenJson(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want [][]string
+	}{
+		{
+			name: "嵌套对象",
+			in:   `[{"Id":1,"Pos":{"X":1.5,"Y":2}},{"Id":2,"Pos":{"X":3,"Y":null}}]`,
+			want: [][]string{
+				{"id", "pos.x", "pos.y"},
+				{"id", "pos.x", "pos.y"},
+				{"int", "float", "int"},
+				{"cs", "cs", "cs"},
+				{"", "", ""},
+				{"1", "1.5", "2"},
+				{"2", "3", ""},
+			},
+		},
+		{
+			name: "对象数组按下标展开",
+			in:   `[{"Id":1,"Drops":[{"Id":10,"Num":1},{"Id":11,"Num":2}]},{"Id":2,"Drops":[{"Id":12,"Num":3}]}]`,
+			want: [][]string{
+				{"id", "drops.0.id", "drops.0.num", "drops.1.id", "drops.1.num"},
+				{"id", "drops.0.id", "drops.0.num", "drops.1.id", "drops.1.num"},
+				{"int", "int", "int", "int", "int"},
+				{"cs", "cs", "cs", "cs", "cs"},
+				{"", "", "", "", ""},
+				{"1", "10", "1", "11", "2"},
+				{"2", "12", "3", "", ""},
+			},
+		},
+		{
+			name: "基础类型数组展开成n列",
+			in:   `[{"Tags":["a","b"],"Open":true},{"Tags":["c","d","e"],"Open":false}]`,
+			want: [][]string{
+				{"tags", "tags", "tags", "open"},
+				{"tags", "tags", "tags", "open"},
+				{"string", "string", "string", "bool"},
+				{"cs", "cs", "cs", "cs"},
+				{"", "", "", ""},
+				{"a", "b", "", "true"},
+				{"c", "d", "e", "false"},
+			},
+		},
+		{
+			name: "超出int范围按float",
+			in:   `[{"Big":1},{"Big":3000000000}]`,
+			want: [][]string{
+				{"big"},
+				{"big"},
+				{"float"},
+				{"cs"},
+				{""},
+				{"1"},
+				{"3000000000"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := flattenJson("test.json", []byte(tt.in))
+			if err != nil {
+				t.Fatalf("flattenJson error %v", err)
+			}
+
+			if got := table.tableRows(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tableRows() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenJsonError(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{name: "最外层不是数组", in: `{"Id":1}`},
+		{name: "行不是对象", in: `[1,2]`},
+		{name: "前后类型不一致", in: `[{"Id":1},{"Id":"a"}]`},
+		{name: "数组和非数组", in: `[{"Id":[1]},{"Id":2}]`},
+		{name: "数组中同时有对象和基础类型", in: `[{"Drops":[{"Id":1},2]}]`},
+		{name: "数组元素是数组", in: `[{"Drops":[[1]]}]`},
+		{name: "数组元素类型不一致", in: `[{"Tags":[1,"a"]}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := flattenJson("test.json", []byte(tt.in)); err == nil {
+				t.Errorf("flattenJson(%s) want error", tt.in)
+			}
+		})
+	}
+}
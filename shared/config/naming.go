@@ -0,0 +1,226 @@
+package config
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+	"unicode"
+)
+
+// 输出类型
+const (
+	OutputProto  = "proto"
+	OutputCSharp = "cs"
+	OutputGo     = "go"
+	OutputTsv    = "tsv"
+)
+
+// 命名风格
+const (
+	CaseKeep   = "keep"   // 保持表格中的写法
+	CaseSnake  = "snake"  // hero_id
+	CaseCamel  = "camel"  // heroId
+	CasePascal = "pascal" // HeroId
+
+	// CaseLegacy 服务器tsv表头原来的规则：先把ID替换成Id，再在大写字母前加下划线（连续大写只加一次）后转小写，不处理其他缩写、非法字符和关键字
+	// Skill_ID -> skill__id，HeroID -> hero_id，IDCard -> id_card，服务器按表头读取字段，默认保持不变
+	CaseLegacy = "legacy"
+)
+
+// NamingPolicy 表格标题、match_text 转换成各输出中的名字，proto、C#、Go、TSV 使用同一套规则
+// proto_id.yaml 的key始终使用表格中的原始标题，修改命名风格不会改变字段ID
+type NamingPolicy struct {
+	// key=输出类型 value=命名风格
+	Case map[string]string
+
+	// 和关键字重名时在后面加的后缀
+	KeywordSuffix string
+
+	// 转换前当成一个单词的缩写，例如 ID -> Id，HeroID 转成 hero_id 而不是 hero_i_d
+	Acronyms []string
+}
+
+var Naming = &NamingPolicy{
+	Case: map[string]string{
+		OutputProto:  CaseKeep,
+		OutputCSharp: CaseKeep,
+		OutputGo:     CaseKeep,
+		OutputTsv:    CaseLegacy,
+	},
+	KeywordSuffix: "_",
+	Acronyms:      []string{"ID"},
+}
+
+// 各输出中不能作为名字的关键字，只转义目标语言中确实不合法的名字
+// protoc允许字段名和proto关键字重名（int32 max = 1;），生成的C#、Go成员名也会重新转换，proto不转义
+// Go常量都带类型名前缀，TSV只是表头，不需要转义
+var keywords = map[string]map[string]struct{}{
+	OutputCSharp: wordSet("abstract as base bool break byte case catch char checked class const continue decimal default " +
+		"delegate do double else enum event explicit extern false finally fixed float for foreach goto if implicit in int " +
+		"interface internal is lock long namespace new null object operator out override params private protected public " +
+		"readonly ref return sbyte sealed short sizeof stackalloc static string struct switch this throw true try typeof " +
+		"uint ulong unchecked unsafe ushort using virtual void volatile while"),
+}
+
+func wordSet(words string) map[string]struct{} {
+	m := map[string]struct{}{}
+	for _, w := range strings.Fields(words) {
+		m[w] = struct{}{}
+	}
+	return m
+}
+
+// SetCase 设置某个输出的命名风格
+func (p *NamingPolicy) SetCase(output string, style string) error {
+	switch style {
+	case CaseKeep, CaseSnake, CaseCamel, CasePascal:
+	case CaseLegacy:
+		if output != OutputTsv {
+			return errors.Errorf("命名风格 legacy 只能用于tsv表头 %v", output)
+		}
+	default:
+		return errors.Errorf("不支持的命名风格 %v：%v，可选 keep snake camel pascal，tsv还可以使用legacy", output, style)
+	}
+
+	p.Case[output] = style
+	return nil
+}
+
+// Signature 命名规则的签名，命名规则变化后生成的proto需要重新生成
+func (p *NamingPolicy) Signature() string {
+	return fmt.Sprintf("%v|%v|%v|%v|%v|%v", p.Case[OutputProto], p.Case[OutputCSharp], p.Case[OutputGo], p.Case[OutputTsv], p.KeywordSuffix, p.Acronyms)
+}
+
+// Name 按命名风格转换，然后把不合法的字符替换掉，和关键字重名时加后缀
+// proto只能使用ASCII字符，中文等字符转换成 u+编码，例如 攻击 -> u653b_u51fb
+func (p *NamingPolicy) Name(output string, name string) string {
+	if p.Case[output] == CaseLegacy {
+		return toLowerSnakeCase(strings.ReplaceAll(name, "ID", "Id"))
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+
+	if style := p.Case[output]; style != "" && style != CaseKeep {
+		for _, acronym := range p.Acronyms {
+			if len(acronym) > 1 {
+				name = strings.ReplaceAll(name, acronym, acronym[:1]+strings.ToLower(acronym[1:]))
+			}
+		}
+
+		name = ConvertCase(style, name)
+	}
+
+	name = SanitizeIdentifier(name, output == OutputProto)
+
+	if _, isKeyword := keywords[output][name]; isKeyword {
+		name += p.KeywordSuffix
+	}
+
+	return name
+}
+
+// splitWords 按下划线、空白等分隔符和小写后面的大写字母拆分单词，连续的大写字母是同一个单词
+func splitWords(name string) []string {
+	var words []string
+
+	word := strings.Builder{}
+	lastWasUpper := false
+
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			lastWasUpper = false
+			continue
+		}
+
+		if unicode.IsUpper(r) && !lastWasUpper && word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+
+		lastWasUpper = unicode.IsUpper(r)
+		word.WriteRune(r)
+	}
+
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+
+	return words
+}
+
+// ConvertCase 按命名风格转换，不处理非法字符
+func ConvertCase(style string, name string) string {
+	if style == "" || style == CaseKeep {
+		return name
+	}
+
+	words := splitWords(name)
+
+	for i, w := range words {
+		w = strings.ToLower(w)
+
+		if style == CasePascal || (style == CaseCamel && i > 0) {
+			runes := []rune(w)
+			runes[0] = unicode.ToUpper(runes[0])
+			w = string(runes)
+		}
+
+		words[i] = w
+	}
+
+	if style == CaseSnake {
+		return strings.Join(words, "_")
+	}
+
+	return strings.Join(words, "")
+}
+
+// SanitizeIdentifier 非字母数字下划线替换成下划线，数字开头前面加下划线
+// asciiOnly 时非ASCII字符转换成 u+编码
+func SanitizeIdentifier(name string, asciiOnly bool) string {
+	sb := strings.Builder{}
+
+	// 编码后的字符和后面的字母数字之间加下划线
+	needSep := false
+
+	for i, r := range strings.TrimSpace(name) {
+		if i == 0 && unicode.IsDigit(r) {
+			sb.WriteRune('_')
+		}
+
+		encode := r >= unicode.MaxASCII && unicode.IsLetter(r) && asciiOnly
+
+		if needSep && !encode && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			sb.WriteRune('_')
+		}
+		needSep = false
+
+		switch {
+		case r == '_' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))):
+			sb.WriteRune(r)
+		case r >= unicode.MaxASCII && unicode.IsLetter(r) && !asciiOnly:
+			sb.WriteRune(r)
+		case encode:
+			if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "_") {
+				sb.WriteRune('_')
+			}
+			sb.WriteString(fmt.Sprintf("u%04x", r))
+			needSep = true
+		default:
+			sb.WriteRune('_')
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "_"
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+)
+
+// TestNamingTsvLegacy 服务器按表头读取字段，默认规则必须和原来的 ID -> Id 加 toLowerSnakeCase 完全一致
+func TestNamingTsvLegacy(t *testing.T) {
+	tests := map[string]string{
+		"id":            "id",
+		"ID":            "id",
+		"HeroID":        "hero_id",
+		"MonsterIDList": "monster_id_list",
+		"IDCard":        "id_card",
+		"IDType":        "id_type",
+		"Skill_ID":      "skill__id",
+		"buff_Type":     "buff__type",
+		"1st":           "1st",
+		"maxHP":         "max_hp",
+		"max":           "max",
+		"string":        "string",
+		"攻击":            "攻击",
+		"":              "",
+	}
+
+	for in, want := range tests {
+		if got := Naming.Name(OutputTsv, in); got != want {
+			t.Errorf("Name(tsv, %q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNamingKeywords(t *testing.T) {
+	tests := []struct {
+		output string
+		in     string
+		want   string
+	}{
+		// protoc允许和关键字重名的字段名
+		{output: OutputProto, in: "max", want: "max"},
+		{output: OutputProto, in: "to", want: "to"},
+		{output: OutputProto, in: "map", want: "map"},
+		{output: OutputProto, in: "string", want: "string"},
+		{output: OutputProto, in: "optional", want: "optional"},
+		{output: OutputProto, in: "1st", want: "_1st"},
+		{output: OutputCSharp, in: "default", want: "default_"},
+		{output: OutputCSharp, in: "max", want: "max"},
+		{output: OutputGo, in: "type", want: "type"},
+	}
+
+	for _, tt := range tests {
+		if got := Naming.Name(tt.output, tt.in); got != tt.want {
+			t.Errorf("Name(%s, %q) = %q, want %q", tt.output, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNamingSetCase(t *testing.T) {
+	p := &NamingPolicy{Case: map[string]string{}}
+
+	if err := p.SetCase(OutputTsv, CaseSnake); err != nil || p.Name(OutputTsv, "Skill_ID") != "skill_id" {
+		t.Errorf("SetCase(tsv, snake) = %v, Name = %q", err, p.Name(OutputTsv, "Skill_ID"))
+	}
+
+	if err := p.SetCase(OutputProto, CaseLegacy); err == nil {
+		t.Errorf("SetCase(proto, legacy) want error")
+	}
+
+	if err := p.SetCase(OutputProto, "upper"); err == nil {
+		t.Errorf("SetCase(proto, upper) want error")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type ObjectParser struct {
@@ -120,6 +121,69 @@ func (p *ObjectParser) Bool(key string) bool {
 	return i
 }
 
+// Time datetime/date 列，Excel日期序列值和日期字符串都能解析
+// xlsx生成tsv时日期序列值已经按表格的日期系统转换成字符串（见 normalizeDateCell），这里的序列值只会来自手写的tsv，按1900日期系统处理
+func (p *ObjectParser) Time(key string, loc *time.Location) time.Time {
+	s := p.String(key)
+	if len(s) == 0 {
+		return time.Time{}
+	}
+
+	t, err := ParseDateTime(s, false, loc)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+// Duration duration 列，纯数字按秒处理
+func (p *ObjectParser) Duration(key string) time.Duration {
+	s := p.String(key)
+	if len(s) == 0 {
+		return 0
+	}
+
+	d, err := ParseCellDuration(s)
+	if err != nil {
+		return 0
+	}
+
+	return d
+}
+
+// Fixed fixed(scale) 列，和转表工具使用同一套解析，保证前后端定点数一致
+func (p *ObjectParser) Fixed(key string, scale int64) int64 {
+	s := p.String(key)
+	if len(s) == 0 {
+		return 0
+	}
+
+	i, err := ParseFixed(s, scale)
+	if err != nil {
+		logrus.WithError(err).Errorf("配置解析错误，Fixed %s %s", p.Line(), key)
+		return 0
+	}
+
+	return i
+}
+
+// Fixed32 超出int32范围时和转表工具一样报错，不截断
+func (p *ObjectParser) Fixed32(key string, scale int64) int32 {
+	s := p.String(key)
+	if len(s) == 0 {
+		return 0
+	}
+
+	i, err := ParseFixed32(s, scale)
+	if err != nil {
+		logrus.WithError(err).Errorf("配置解析错误，Fixed32 %s %s", p.Line(), key)
+		return 0
+	}
+
+	return i
+}
+
 func (p *ObjectParser) KeyExist(key string) bool {
 	_, ok := p.dataMap[strings.ToLower(key)]
 	return ok
@@ -189,6 +253,46 @@ func (p *ObjectParser) Int64Array(key, sep string, nullable bool) []int64 {
 	return out
 }
 
+func (p *ObjectParser) FixedArray(key, sep string, scale int64, nullable bool) []int64 {
+	sa := p.StringArray(key, sep, nullable)
+
+	out := make([]int64, 0, len(sa))
+	for _, s := range sa {
+
+		v, err := ParseFixed(s, scale)
+		if err != nil {
+			logrus.Errorf("配置解析错误(之前不是检查过类型吗...)，FixedArray %s %s, %s", key, sep, sa)
+
+			out = append(out, -1)
+			continue
+		}
+
+		out = append(out, v)
+	}
+
+	return out
+}
+
+func (p *ObjectParser) Fixed32Array(key, sep string, scale int64, nullable bool) []int32 {
+	sa := p.StringArray(key, sep, nullable)
+
+	out := make([]int32, 0, len(sa))
+	for _, s := range sa {
+
+		v, err := ParseFixed32(s, scale)
+		if err != nil {
+			logrus.WithError(err).Errorf("配置解析错误(之前不是检查过类型吗...)，Fixed32Array %s %s, %s", key, sep, sa)
+
+			out = append(out, -1)
+			continue
+		}
+
+		out = append(out, v)
+	}
+
+	return out
+}
+
 func (p *ObjectParser) Uint64Array(key, sep string, nullable bool) []uint64 {
 	sa := p.StringArray(key, sep, nullable)
 
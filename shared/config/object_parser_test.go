@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestObjectParserFixed(t *testing.T) {
+	p := NewObjectParser([]string{"speed", "big", "bad", "list"}, []string{"1.5", "3000000", "1/2", "1.5,3000000"}, "test", 3)
+
+	tests := []struct {
+		name string
+		got  int64
+		want int64
+	}{
+		{name: "Fixed", got: p.Fixed("speed", 1000), want: 1500},
+		{name: "Fixed64不截断", got: p.Fixed("big", 1000), want: 3000000000},
+		{name: "Fixed32溢出", got: int64(p.Fixed32("big", 1000)), want: 0},
+		{name: "分数", got: p.Fixed("bad", 1000), want: 0},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+
+	list := p.Fixed32Array("list", ",", 1000, false)
+	if len(list) != 2 || list[0] != 1500 || list[1] != -1 {
+		t.Errorf("Fixed32Array = %v, want [1500 -1]", list)
+	}
+}
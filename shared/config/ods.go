@@ -0,0 +1,360 @@
+package config
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"github.com/pkg/errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	odsTableNs  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsOfficeNs = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsTextNs   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+// odsWorkbook OpenDocument表格（LibreOffice），只读取content.xml中的单元格值
+// 数字按原始数值输出，日期输出 2006-01-02 15:04:05，时长（PT01H30M00S）输出秒数，布尔值和xlsx一样输出1/0
+type odsWorkbook struct {
+	names  []string
+	sheets map[string]*odsSheet
+}
+
+type odsSheet struct {
+	name string
+	rows [][]string
+
+	// key=行列 value=时长单元格的值
+	durations map[[2]int]time.Duration
+
+	// 合并单元格 行 列 行数 列数
+	merges [][4]int
+}
+
+func openOdsWorkbook(data []byte) (Workbook, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "解析ods失败")
+	}
+
+	for _, f := range zr.File {
+		if f.Name != "content.xml" {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return nil, errors.Wrapf(err, "读取ods content.xml失败")
+		}
+		defer r.Close()
+
+		w, err := parseOdsContent(r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "解析ods content.xml失败")
+		}
+
+		return w, nil
+	}
+
+	return nil, errors.Errorf("ods中没有content.xml")
+}
+
+func (w *odsWorkbook) SheetNames() []string {
+	return w.names
+}
+
+func (w *odsWorkbook) Sheet(name string) WorkbookSheet {
+	sheet := w.sheets[name]
+	if sheet == nil {
+		return nil
+	}
+
+	return sheet
+}
+
+func (w *odsWorkbook) Date1904() bool {
+	return false
+}
+
+func (s *odsSheet) Name() string {
+	return s.name
+}
+
+func (s *odsSheet) Rows() ([][]string, error) {
+	return s.rows, nil
+}
+
+func (s *odsSheet) CellDuration(row, col int) (time.Duration, bool) {
+	d, exist := s.durations[[2]int{row, col}]
+	return d, exist
+}
+
+func odsAttr(e xml.StartElement, space string, local string) string {
+	for _, a := range e.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+func odsAttrInt(e xml.StartElement, local string) int {
+	n, err := strconv.Atoi(odsAttr(e, odsTableNs, local))
+	if err != nil || n < 1 {
+		return 1
+	}
+
+	return n
+}
+
+// odsCell 正在读取的单元格
+type odsCell struct {
+	valueType string
+	value     string
+	repeat    int
+	colSpan   int
+	rowSpan   int
+
+	text       strings.Builder
+	paragraphs int
+}
+
+func parseOdsContent(r io.Reader) (*odsWorkbook, error) {
+	w := &odsWorkbook{sheets: map[string]*odsSheet{}}
+
+	decoder := xml.NewDecoder(r)
+
+	var sheet *odsSheet
+	var cell *odsCell
+
+	// 当前行，空单元格先记录数量，后面有值时再补上，避免行尾大量重复的空单元格
+	var row []string
+	var rowDurations map[int]time.Duration
+	rowRepeat := 1
+	emptyCells := 0
+	emptyRows := 0
+
+	// 批注中也有text:p，不读取
+	annotationDepth := 0
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if annotationDepth > 0 || (t.Name.Space == odsOfficeNs && t.Name.Local == "annotation") {
+				annotationDepth++
+				continue
+			}
+
+			switch {
+			case t.Name.Space == odsTableNs && t.Name.Local == "table":
+				sheet = &odsSheet{name: odsAttr(t, odsTableNs, "name"), durations: map[[2]int]time.Duration{}}
+				emptyRows = 0
+			case t.Name.Space == odsTableNs && t.Name.Local == "table-row" && sheet != nil:
+				row = nil
+				rowDurations = map[int]time.Duration{}
+				rowRepeat = odsAttrInt(t, "number-rows-repeated")
+				emptyCells = 0
+			case t.Name.Space == odsTableNs && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell") && sheet != nil:
+				cell = &odsCell{
+					valueType: odsAttr(t, odsOfficeNs, "value-type"),
+					repeat:    odsAttrInt(t, "number-columns-repeated"),
+					colSpan:   odsAttrInt(t, "number-columns-spanned"),
+					rowSpan:   odsAttrInt(t, "number-rows-spanned"),
+				}
+
+				switch cell.valueType {
+				case "date":
+					cell.value = odsAttr(t, odsOfficeNs, "date-value")
+				case "time":
+					cell.value = odsAttr(t, odsOfficeNs, "time-value")
+				case "boolean":
+					cell.value = odsAttr(t, odsOfficeNs, "boolean-value")
+				default:
+					cell.value = odsAttr(t, odsOfficeNs, "value")
+				}
+			case t.Name.Space == odsTextNs && cell != nil:
+				switch t.Name.Local {
+				case "p":
+					if cell.paragraphs > 0 {
+						cell.text.WriteString("\n")
+					}
+					cell.paragraphs++
+				case "s":
+					n, errCount := strconv.Atoi(odsAttr(t, odsTextNs, "c"))
+					if errCount != nil || n < 1 {
+						n = 1
+					}
+					cell.text.WriteString(strings.Repeat(" ", n))
+				case "tab":
+					cell.text.WriteString("\t")
+				case "line-break":
+					cell.text.WriteString("\n")
+				}
+			}
+		case xml.CharData:
+			if annotationDepth == 0 && cell != nil && cell.paragraphs > 0 {
+				cell.text.Write(t)
+			}
+		case xml.EndElement:
+			if annotationDepth > 0 {
+				annotationDepth--
+				continue
+			}
+
+			switch {
+			case t.Name.Space == odsTableNs && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell") && cell != nil:
+				text, duration, isDuration, errCell := cell.result()
+				if errCell != nil {
+					return nil, errors.Wrapf(errCell, "%s %s", sheet.name, CellRef(len(sheet.rows)+emptyRows, len(row)+emptyCells))
+				}
+
+				if text == "" && cell.colSpan == 1 && cell.rowSpan == 1 {
+					emptyCells += cell.repeat
+					cell = nil
+					continue
+				}
+
+				for ; emptyCells > 0; emptyCells-- {
+					row = append(row, "")
+				}
+
+				for i := 0; i < cell.repeat; i++ {
+					if cell.colSpan > 1 || cell.rowSpan > 1 {
+						sheet.merges = append(sheet.merges, [4]int{len(sheet.rows) + emptyRows, len(row), cell.rowSpan, cell.colSpan})
+					}
+
+					if isDuration {
+						rowDurations[len(row)] = duration
+					}
+
+					row = append(row, text)
+				}
+
+				cell = nil
+			case t.Name.Space == odsTableNs && t.Name.Local == "table-row" && sheet != nil:
+				if len(row) == 0 {
+					emptyRows += rowRepeat
+					continue
+				}
+
+				for ; emptyRows > 0; emptyRows-- {
+					sheet.rows = append(sheet.rows, nil)
+				}
+
+				for i := 0; i < rowRepeat; i++ {
+					for col, d := range rowDurations {
+						sheet.durations[[2]int{len(sheet.rows), col}] = d
+					}
+
+					sheet.rows = append(sheet.rows, append([]string{}, row...))
+				}
+			case t.Name.Space == odsTableNs && t.Name.Local == "table" && sheet != nil:
+				if DefaultCellOption.FillMerged {
+					sheet.fillMerged()
+				}
+
+				if _, exist := w.sheets[sheet.name]; !exist {
+					w.names = append(w.names, sheet.name)
+				}
+				w.sheets[sheet.name] = sheet
+				sheet = nil
+			}
+		}
+	}
+
+	return w, nil
+}
+
+// result 单元格的值，时长单元格同时返回时长
+func (c *odsCell) result() (string, time.Duration, bool, error) {
+	switch c.valueType {
+	case "float", "percentage", "currency":
+		f, err := strconv.ParseFloat(c.value, 64)
+		if err != nil {
+			return "", 0, false, errors.Errorf("数字格式不正确 %v", c.value)
+		}
+		return DefaultCellOption.formatNumber(f), 0, false, nil
+	case "date":
+		// 2024-01-02 或者 2024-01-02T10:00:00，去掉小数秒
+		value := strings.Replace(c.value, "T", " ", 1)
+		if i := strings.Index(value, "."); i > 0 {
+			value = value[:i]
+		}
+		return value, 0, false, nil
+	case "time":
+		d, err := parseOdsDuration(c.value)
+		if err != nil {
+			return "", 0, false, err
+		}
+		return strconv.FormatInt(int64(d/time.Second), 10), d, true, nil
+	case "boolean":
+		if c.value == "true" {
+			return "1", 0, false, nil
+		}
+		return "0", 0, false, nil
+	}
+
+	return DefaultCellOption.normalize(c.text.String()), 0, false, nil
+}
+
+var odsDurationRegexp = regexp.MustCompile(`^(-)?P(?:(\d+)D)?T?(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?$`)
+
+// parseOdsDuration ISO 8601时长，例如 PT01H30M00S
+func parseOdsDuration(s string) (time.Duration, error) {
+	m := odsDurationRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, errors.Errorf("时长格式不正确 %v", s)
+	}
+
+	var seconds float64
+	for i, unit := range []float64{86400, 3600, 60, 1} {
+		if m[i+2] == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(m[i+2], 64)
+		if err != nil {
+			return 0, errors.Errorf("时长格式不正确 %v", s)
+		}
+		seconds += v * unit
+	}
+
+	d := time.Duration(seconds+0.5) * time.Second
+	if m[1] == "-" {
+		d = -d
+	}
+
+	return d, nil
+}
+
+// fillMerged 合并单元格的值填充到整个合并区域，和xlsx的 CellOption.FillMerged 一致
+func (s *odsSheet) fillMerged() {
+	for _, m := range s.merges {
+		row, col, rowSpan, colSpan := m[0], m[1], m[2], m[3]
+		value := SheetCell(s.rows, row, col)
+
+		for r := row; r < row+rowSpan && r < len(s.rows); r++ {
+			for len(s.rows[r]) < col+colSpan {
+				s.rows[r] = append(s.rows[r], "")
+			}
+
+			for c := col; c < col+colSpan; c++ {
+				s.rows[r][c] = value
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const odsTestContent = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content
+	xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:spreadsheet>
+<table:table table:name="Data">
+	<table:table-row>
+		<table:table-cell office:value-type="float" office:value="1.5"><text:p>1.5</text:p></table:table-cell>
+		<table:table-cell office:value-type="percentage" office:value="0.25"><text:p>25%</text:p></table:table-cell>
+		<table:table-cell office:value-type="date" office:date-value="2024-01-02T10:00:00.123"><text:p>2024/1/2</text:p></table:table-cell>
+		<table:table-cell office:value-type="time" office:time-value="PT01H30M00S"><text:p>01:30:00</text:p></table:table-cell>
+		<table:table-cell office:value-type="boolean" office:boolean-value="true"><text:p>TRUE</text:p></table:table-cell>
+	</table:table-row>
+	<table:table-row table:number-rows-repeated="2"><table:table-cell table:number-columns-repeated="5"/></table:table-row>
+	<table:table-row>
+		<table:table-cell table:number-columns-spanned="2" table:number-rows-spanned="2" office:value-type="string"><text:p>a<text:s text:c="2"/>b</text:p><office:annotation><text:p>批注</text:p></office:annotation></table:table-cell>
+		<table:covered-table-cell/>
+		<table:table-cell table:number-columns-repeated="2" office:value-type="string"><text:p>x</text:p></table:table-cell>
+	</table:table-row>
+	<table:table-row>
+		<table:covered-table-cell table:number-columns-repeated="2"/>
+		<table:table-cell office:value-type="string"><text:p>l1</text:p><text:p>l2</text:p></table:table-cell>
+	</table:table-row>
+	<table:table-row table:number-rows-repeated="1000"><table:table-cell/></table:table-row>
+</table:table>
+<table:table table:name="Empty"/>
+</office:spreadsheet></office:body>
+</office:document-content>`
+
+func TestParseOdsContent(t *testing.T) {
+	tests := []struct {
+		name       string
+		fillMerged bool
+		want       [][]string
+	}{
+		{
+			name: "默认不填充合并单元格",
+			want: [][]string{
+				{"1.5", "0.25", "2024-01-02 10:00:00", "5400", "1"},
+				nil,
+				nil,
+				{"a  b", "", "x", "x"},
+				{"", "", "l1\nl2"},
+			},
+		},
+		{
+			name:       "填充合并单元格",
+			fillMerged: true,
+			want: [][]string{
+				{"1.5", "0.25", "2024-01-02 10:00:00", "5400", "1"},
+				nil,
+				nil,
+				{"a  b", "a  b", "x", "x"},
+				{"a  b", "a  b", "l1\nl2"},
+			},
+		},
+	}
+
+	defer func(opt *CellOption) { DefaultCellOption = opt }(DefaultCellOption)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			DefaultCellOption = &CellOption{ReplaceTab: true, RemoveQuote: true, FillMerged: tt.fillMerged}
+
+			w, err := parseOdsContent(strings.NewReader(odsTestContent))
+			if err != nil {
+				t.Fatalf("parseOdsContent error %v", err)
+			}
+
+			if names := w.SheetNames(); !reflect.DeepEqual(names, []string{"Data", "Empty"}) {
+				t.Errorf("SheetNames() = %v", names)
+			}
+
+			sheet := w.Sheet("Data")
+			rows, _ := sheet.Rows()
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("Rows() = %q, want %q", rows, tt.want)
+			}
+
+			if d, exist := sheet.CellDuration(0, 3); !exist || d != 90*time.Minute {
+				t.Errorf("CellDuration(0, 3) = %v, %v", d, exist)
+			}
+
+			if _, exist := sheet.CellDuration(0, 0); exist {
+				t.Errorf("CellDuration(0, 0) should not exist")
+			}
+		})
+	}
+}
+
+func TestParseOdsContentError(t *testing.T) {
+	content := strings.Replace(odsTestContent, `office:value="1.5"`, `office:value="abc"`, 1)
+	if _, err := parseOdsContent(strings.NewReader(content)); err == nil {
+		t.Errorf("parseOdsContent with bad number want error")
+	}
+}
+
+func TestOpenOdsWorkbook(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("content.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(odsTestContent))
+	zw.Close()
+
+	w, err := openOdsWorkbook(buf.Bytes())
+	if err != nil {
+		t.Fatalf("openOdsWorkbook error %v", err)
+	}
+
+	if w.Sheet("Data") == nil || w.Sheet("None") != nil {
+		t.Errorf("Sheet() lookup wrong")
+	}
+
+	if _, err := openOdsWorkbook([]byte("not a zip")); err == nil {
+		t.Errorf("openOdsWorkbook with bad data want error")
+	}
+}
+
+func TestParseOdsDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "PT01H30M00S", want: 90 * time.Minute},
+		{in: "PT00H00M01.6S", want: 2 * time.Second},
+		{in: "P1DT2H", want: 26 * time.Hour},
+		{in: "-PT10M", want: -10 * time.Minute},
+		{in: "PT36H", want: 36 * time.Hour},
+		{in: "01:30:00", wantErr: true},
+		{in: "PT1.5.5S", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseOdsDuration(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseOdsDuration(%q) = %v, want error", tt.in, got)
+			}
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("parseOdsDuration(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"github.com/pkg/errors"
+	"github.com/tealeg/xlsx"
+	"math"
+	"math/big"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -94,3 +99,156 @@ func ParseDuration(s string) (time.Duration, error) {
 
 	return time.Duration(0), nil
 }
+
+// ParseCellDuration 表格中的时长，纯数字按秒处理，其他按 ParseDuration 的格式（1h30m）
+func ParseCellDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return ParseDuration(s)
+}
+
+var dateTimeLayouts = []string{
+	"2006-1-2 15:04:05",
+	"2006-1-2 15:04",
+	"2006-1-2",
+	"2006/1/2 15:04:05",
+	"2006/1/2 15:04",
+	"2006/1/2",
+}
+
+// MaxExcelTime Excel支持的最大日期序列值 9999-12-31
+const MaxExcelTime = 2958465
+
+// ParseDateTime 表格中的时间，支持Excel日期序列值、常用日期格式和RFC3339，没有时区的按loc处理
+// 数字只接受Excel日期范围内的序列值，文本 20230101 这样的数字不会当成序列值
+func ParseDateTime(s string, date1904 bool, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	if excelTime, err := strconv.ParseFloat(s, 64); err == nil {
+		if !IsExcelTime(excelTime) {
+			return time.Time{}, errors.Errorf("数字超出Excel日期范围（0-%d），日期请填写 2006-01-02 格式, %s", MaxExcelTime, s)
+		}
+		return ExcelTimeToTime(excelTime, date1904, loc), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.Errorf("时间格式不正确，支持 2006-01-02 15:04:05 或者Excel日期格式, %s", s)
+}
+
+// IsExcelTime 是否是Excel日期范围内的序列值
+func IsExcelTime(excelTime float64) bool {
+	return excelTime >= 0 && excelTime < MaxExcelTime+1
+}
+
+// ExcelTimeToTime Excel日期序列值转换成loc时区下的时间，精确到秒
+func ExcelTimeToTime(excelTime float64, date1904 bool, loc *time.Location) time.Time {
+	t := xlsx.TimeFromExcelTime(excelTime, date1904).Round(time.Second)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+}
+
+// ParseLocation 时区，支持 Local、UTC、Asia/Shanghai、+08:00、+0800、+8
+func ParseLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+
+	switch strings.ToLower(name) {
+	case "", "local":
+		return time.Local, nil
+	case "utc":
+		return time.UTC, nil
+	}
+
+	if name[0] != '+' && name[0] != '-' {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "时区解析失败, %s", name)
+		}
+		return loc, nil
+	}
+
+	offset := strings.ReplaceAll(name[1:], ":", "")
+	if len(offset) <= 2 {
+		offset += "00"
+	}
+
+	if len(offset) != 4 && len(offset) != 3 {
+		return nil, errors.Errorf("时区偏移格式不正确, %s", name)
+	}
+
+	hours, errHour := strconv.Atoi(offset[:len(offset)-2])
+	minutes, errMinute := strconv.Atoi(offset[len(offset)-2:])
+	if errHour != nil || errMinute != nil || hours > 14 || minutes >= 60 {
+		return nil, errors.Errorf("时区偏移格式不正确, %s", name)
+	}
+
+	seconds := hours*3600 + minutes*60
+	if name[0] == '-' {
+		seconds = -seconds
+	}
+
+	return time.FixedZone(fmt.Sprintf("UTC%c%02d:%02d", name[0], hours, minutes), seconds), nil
+}
+
+// ExcelFractionToDuration 时间格式的单元格（1:30:00）按天的小数存储
+func ExcelFractionToDuration(fraction float64) time.Duration {
+	return time.Duration(math.Round(fraction*86400)) * time.Second
+}
+
+// fixedPattern 定点数只接受十进制小数，分数（1/2）、科学计数法（1e3）都不支持
+var fixedPattern = regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?$`)
+
+// ParseFixed 十进制字符串按scale放大成定点整数，全程不经过浮点运算，保证前后端结果一致
+// 放大后不是整数时报错，不做舍入；Excel公式结果带浮点误差（0.30000000000000004）时需要在表格中用ROUND处理
+func ParseFixed(s string, scale int64) (int64, error) {
+	s = strings.TrimSpace(s)
+
+	if scale <= 0 {
+		return 0, errors.Errorf("定点数放大倍数必须大于0, scale: %d", scale)
+	}
+
+	if !fixedPattern.MatchString(s) {
+		return 0, errors.Errorf("定点数格式不正确，只支持十进制小数, %s", s)
+	}
+
+	value, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, errors.Errorf("定点数格式不正确, %s", s)
+	}
+	value.Mul(value, new(big.Rat).SetInt64(scale))
+
+	if !value.IsInt() {
+		return 0, errors.Errorf("定点数精度超出放大倍数, %s scale: %d", s, scale)
+	}
+
+	if !value.Num().IsInt64() {
+		return 0, errors.Errorf("定点数超出int64范围, %s scale: %d", s, scale)
+	}
+
+	return value.Num().Int64(), nil
+}
+
+// ParseFixed32 fixed(scale) 列生成int32，超出范围时报错，不截断
+func ParseFixed32(s string, scale int64) (int32, error) {
+	v, err := ParseFixed(s, scale)
+	if err != nil {
+		return 0, err
+	}
+
+	if v > math.MaxInt32 || v < math.MinInt32 {
+		return 0, errors.Errorf("定点数超出int32范围，需要使用fixed64, %s scale: %d", s, scale)
+	}
+
+	return int32(v), nil
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateTime(t *testing.T) {
+	loc := time.FixedZone("UTC+08:00", 8*3600)
+
+	tests := []struct {
+		name     string
+		in       string
+		date1904 bool
+		want     string
+		wantErr  bool
+	}{
+		{name: "序列值", in: "45000", want: "2023-03-15 00:00:00"},
+		{name: "序列值带时间", in: "45000.5", want: "2023-03-15 12:00:00"},
+		{name: "1904日期系统", in: "45000", date1904: true, want: "2027-03-16 00:00:00"},
+		{name: "日期", in: "2023-1-2", want: "2023-01-02 00:00:00"},
+		{name: "斜杠日期时间", in: "2023/01/02 10:30", want: "2023-01-02 10:30:00"},
+		{name: "RFC3339按自带时区", in: "2023-01-02T00:00:00Z", want: "2023-01-02 08:00:00"},
+		{name: "首尾空白", in: " 2023-01-02 10:00:00 ", want: "2023-01-02 10:00:00"},
+		{name: "文本数字不是序列值", in: "20230101", wantErr: true},
+		{name: "负数", in: "-1", wantErr: true},
+		{name: "格式错误", in: "2023年1月2日", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDateTime(tt.in, tt.date1904, loc)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseDateTime(%q) = %v, want error", tt.in, got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseDateTime(%q) error %v", tt.in, err)
+			}
+
+			if s := got.In(loc).Format("2006-01-02 15:04:05"); s != tt.want {
+				t.Errorf("ParseDateTime(%q) = %s, want %s", tt.in, s, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeDateCell(t *testing.T) {
+	tests := []struct {
+		in       string
+		date1904 bool
+		want     string
+	}{
+		{in: "45000", want: "2023-03-15 00:00:00"},
+		{in: "45000", date1904: true, want: "2027-03-16 00:00:00"},
+		{in: "2023-01-02", want: "2023-01-02"},
+		{in: "20230101", want: "20230101"},
+		{in: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeDateCell(tt.in, tt.date1904); got != tt.want {
+			t.Errorf("normalizeDateCell(%q, %v) = %q, want %q", tt.in, tt.date1904, got, tt.want)
+		}
+	}
+}
+
+func TestIsDateType(t *testing.T) {
+	tests := map[string]bool{
+		"datetime":                true,
+		"Date":                    true,
+		"datetime(Asia/Shanghai)": true,
+		"date?":                   true,
+		"datetime,notnil":         true,
+		"duration":                false,
+		"date_list":               false,
+		"string":                  false,
+	}
+
+	for in, want := range tests {
+		if got := isDateType(in); got != want {
+			t.Errorf("isDateType(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestParseFixed(t *testing.T) {
+	tests := []struct {
+		in      string
+		scale   int64
+		want    int64
+		wantErr bool
+	}{
+		{in: "1.5", scale: 1000, want: 1500},
+		{in: "-0.001", scale: 1000, want: -1},
+		{in: "+2", scale: 100, want: 200},
+		{in: " 0.3 ", scale: 10, want: 3},
+		{in: "0.1", scale: 1024, wantErr: true},
+		{in: "0.5", scale: 1024, want: 512},
+		{in: "1/2", scale: 1000, wantErr: true},
+		{in: "1e3", scale: 1000, wantErr: true},
+		{in: ".5", scale: 1000, wantErr: true},
+		{in: "5.", scale: 1000, wantErr: true},
+		{in: "0.30000000000000004", scale: 1000, wantErr: true},
+		{in: "0.0001", scale: 1000, wantErr: true},
+		{in: "9223372036854775807", scale: 1, want: 9223372036854775807},
+		{in: "9223372036854775808", scale: 1, wantErr: true},
+		{in: "1", scale: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFixed(tt.in, tt.scale)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseFixed(%q, %d) = %d, want error", tt.in, tt.scale, got)
+			}
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("ParseFixed(%q, %d) = %d, %v, want %d", tt.in, tt.scale, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseFixed32(t *testing.T) {
+	if got, err := ParseFixed32("2147483.647", 1000); err != nil || got != 2147483647 {
+		t.Errorf("ParseFixed32 max = %d, %v", got, err)
+	}
+
+	if got, err := ParseFixed32("2147483.648", 1000); err == nil {
+		t.Errorf("ParseFixed32 overflow = %d, want error", got)
+	}
+
+	if got, err := ParseFixed32("-2147483.649", 1000); err == nil {
+		t.Errorf("ParseFixed32 underflow = %d, want error", got)
+	}
+}
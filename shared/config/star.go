@@ -1,18 +1,18 @@
 package config
 
 import (
-	"fmt"
 	"github.com/axgle/mahonia"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
-	"github.com/tealeg/xlsx"
 	unicode16 "golang.org/x/text/encoding/unicode"
+	"path"
 	"path/filepath"
 	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unicode/utf8"
 )
 
@@ -29,21 +29,18 @@ func NewStarGameObjects(dir string) (*GameObjects, error) {
 	}
 	dirWithSep := dir + "/"
 
-	if fss, err := ReadDir(dir); err != nil {
+	// 包括子目录，数据的key只使用文件名（Hero.xlsx:MainData），表格移动到子目录不影响服务器读取，不同目录下的同名文件报错
+	if fss, err := WalkDir(dir); err != nil {
 		return nil, err
 	} else {
-		fmap := make(map[string]struct{})
-		for _, f := range fss {
-			fname := f.Name()
+		// key=完整路径 value=相对dir的路径
+		fmap := make(map[string]string)
+		nameMap := make(map[string]string)
+		for _, fname := range fss {
 			path := dirWithSep + fname
-			if filepath.Ext(fname) != ".xlsx" {
-				if filepath.Ext(fname) == ".tsv" {
-					data, err := ReadFile(path)
-					if err != nil {
-						return nil, err
-					}
-					tsvMap[path] = data
-				}
+			ext := filepath.Ext(fname)
+
+			if !IsWorkbook(fname) && ext != ".tsv" {
 				continue
 			}
 
@@ -51,11 +48,25 @@ func NewStarGameObjects(dir string) (*GameObjects, error) {
 				continue
 			}
 
-			fmap[path] = struct{}{}
+			if other, exist := nameMap[filepath.Base(fname)]; exist {
+				return nil, errors.Errorf("不同目录下的文件重名 %s %s", other, fname)
+			}
+			nameMap[filepath.Base(fname)] = fname
+
+			if ext == ".tsv" {
+				data, err := ReadFile(path)
+				if err != nil {
+					return nil, err
+				}
+				tsvMap[path] = data
+				continue
+			}
+
+			fmap[path] = fname
 		}
 
-		for k := range fmap {
-			path := k
+		for k, rel := range fmap {
+			path, rel := k, rel
 
 			wg.Add(1)
 			go func() {
@@ -89,6 +100,8 @@ func NewStarGameObjects(dir string) (*GameObjects, error) {
 					return
 				}
 
+				file.rel = rel
+
 				loadMux.Lock()
 				defer loadMux.Unlock()
 				fileMap[file.name] = file
@@ -117,13 +130,11 @@ func NewStarGameObjects(dir string) (*GameObjects, error) {
 func parseStarFiles(fileMap map[string]*starfile) (*GameObjects, error) {
 
 	// 处理filter的替换
-	matchMap := make(map[string]map[string]string)
+	matchMap := make(MatchTables)
 	for _, file := range fileMap {
-		name := strings.TrimSuffix(file.name, ".xlsx")
 		for _, sheet := range file.sheets {
 			if len(sheet.matchMap) > 0 {
-				key := strings.ToLower(name + sheet.sheet.Name)
-				matchMap[key] = sheet.matchMap
+				matchMap[MatchKey(file.rel, sheet.sheet.Name())] = sheet.matchMap
 			}
 		}
 	}
@@ -169,35 +180,23 @@ func parseStarFiles(fileMap map[string]*starfile) (*GameObjects, error) {
 				sb := strings.Builder{}
 
 				// 2行表头
-				headRow := sheet.Rows[1]
+				headRow := starSheet.rows[1]
 				for _, idx := range starSheet.serverIndex {
-					if idx >= len(headRow.Cells) {
+					if idx >= len(headRow) {
 						sb.WriteString("")
 						sb.WriteString("\t")
 						continue
 					}
 
-					cell := headRow.Cells[idx]
-					text, err := getCellString(cell)
-					if err != nil {
-						logrus.WithError(err).Errorf("getCellString fail, %s", cell.String())
-						if loadErrorRef.Load() == nil {
-							loadErrorRef.Store(errors.Wrapf(err, "读取表头[%v] getCellString fail %s", idx+1, cell))
-						}
-						return
-					}
-
-					// 特殊转换，ID -> Id
-					text = strings.ReplaceAll(text, "ID", "Id")
-
-					sb.WriteString(toLowerSnakeCase(text))
+					// 默认和原来的表头一致，Skill_ID -> skill__id，见 Naming
+					sb.WriteString(Naming.Name(OutputTsv, headRow[idx]))
 					sb.WriteString("\t")
 				}
 				sb.WriteString("\r\n")
 				sb.WriteString(sb.String()) // 第二行表头
 
-				for i := 5; i < len(sheet.Rows); i++ {
-					row := sheet.Rows[i]
+				for i := 5; i < len(starSheet.rows); i++ {
+					row := starSheet.rows[i]
 
 					if isEmptyRow(row, starSheet) {
 						continue
@@ -206,30 +205,22 @@ func parseStarFiles(fileMap map[string]*starfile) (*GameObjects, error) {
 					for i, idx := range starSheet.serverIndex {
 
 						def := starSheet.defVal[i]
-						if idx >= len(row.Cells) {
+						if idx >= len(row) {
 							sb.WriteString(def)
 							sb.WriteString("\t")
 							continue
 						}
-						cell := row.Cells[idx]
 
-						text, err := getCellString(cell)
-						if err != nil {
-							logrus.WithError(err).Errorf("getCellString fail, %s", cell.String())
-							if loadErrorRef.Load() == nil {
-								loadErrorRef.Store(errors.Wrapf(err, "读取表头[%v] getCellString fail %s", idx+1, cell))
-							}
-							return
-						}
+						text := row[idx]
 
 						if matchNames, exist := starSheet.filterMap[idx]; exist {
 
 							// {haha}_1_{hehe}_2
-							newValue, err := replaceMatchString(text, matchMap, matchNames)
+							newValue, err := ReplaceMatchString(text, matchMap, matchNames)
 							if err != nil {
-								logrus.WithError(err).Errorf("%s/%s filter_string替换失败 %d行-%d列 %v, matchNames: %v", file.name, sheet.Name, i+1, idx+1, text, matchNames)
+								logrus.WithError(err).Errorf("%s/%s filter_string替换失败 %d行-%d列 %v, matchNames: %v", file.name, sheet.Name(), i+1, idx+1, text, matchNames)
 								if loadErrorRef.Load() == nil {
-									loadErrorRef.Store(errors.Wrapf(err, "%s/%s filter_string替换失败 %d行-%d列 %v, matchNames: %v", file.name, sheet.Name, i+1, idx+1, text, matchNames))
+									loadErrorRef.Store(errors.Wrapf(err, "%s/%s filter_string替换失败 %d行-%d列 %v, matchNames: %v", file.name, sheet.Name(), i+1, idx+1, text, matchNames))
 								}
 								return
 							}
@@ -240,20 +231,24 @@ func parseStarFiles(fileMap map[string]*starfile) (*GameObjects, error) {
 							text = def
 						}
 
+						if starSheet.dateIndex[idx] {
+							text = normalizeDateCell(text, file.date1904)
+						}
+
 						sb.WriteString(text)
 						sb.WriteString("\t")
 					}
 					sb.WriteString("\r\n")
 				}
 
-				filename := file.name + ":" + sheet.Name
+				filename := file.name + ":" + sheet.Name()
 
 				loadMux.Lock()
 				defer loadMux.Unlock()
 				if _, exist := gos.dataMap[filename]; exist {
-					logrus.Errorf("%s/%s 文件重复", file.name, sheet.Name)
+					logrus.Errorf("%s/%s 文件重复", file.name, sheet.Name())
 					if loadErrorRef.Load() == nil {
-						loadErrorRef.Store(errors.Errorf("%s/%s 文件重复", file.name, sheet.Name))
+						loadErrorRef.Store(errors.Errorf("%s/%s 文件重复", file.name, sheet.Name()))
 					}
 				}
 
@@ -271,19 +266,35 @@ func parseStarFiles(fileMap map[string]*starfile) (*GameObjects, error) {
 	return gos, nil
 }
 
-func isEmptyRow(row *xlsx.Row, starSheet *starsheet) bool {
+// isDateType 类型行是 datetime/date，可以带时区参数、可空和校验规则
+func isDateType(typeText string) bool {
+	typeStr, _ := SplitTypeRules(typeText)
+	typeStr = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(typeStr), "?"))
+	if i := strings.Index(typeStr, "("); i > 0 {
+		typeStr = typeStr[:i]
+	}
+
+	typeStr = strings.ToLower(strings.TrimSpace(typeStr))
+	return typeStr == "datetime" || typeStr == "date"
+}
+
+// normalizeDateCell tsv中没有表格的日期系统，日期序列值按表格的日期系统转换成日期字符串，ObjectParser.Time 按列的时区解析
+func normalizeDateCell(text string, date1904 bool) string {
+	excelTime, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || !IsExcelTime(excelTime) {
+		return text
+	}
+
+	return ExcelTimeToTime(excelTime, date1904, time.UTC).Format("2006-01-02 15:04:05")
+}
+
+func isEmptyRow(row []string, starSheet *starsheet) bool {
 	for _, idx := range starSheet.serverIndex {
-		if idx >= len(row.Cells) {
+		if idx >= len(row) {
 			break
 		}
-		cell := row.Cells[idx]
-
-		text, err := getCellString(cell)
-		if err != nil {
-			return false
-		}
 
-		if text != "" {
+		if row[idx] != "" {
 			return false
 		}
 	}
@@ -293,19 +304,29 @@ func isEmptyRow(row *xlsx.Row, starSheet *starsheet) bool {
 type starfile struct {
 	name string
 
-	file *xlsx.File
+	// 相对配置目录的路径，match表名使用，见 MatchKey
+	rel string
+
+	// 日期序列值使用1904日期系统，见 normalizeDateCell
+	date1904 bool
 
 	sheets []*starsheet
 }
 
 type starsheet struct {
-	sheet *xlsx.Sheet
+	sheet WorkbookSheet
+
+	// 读取后的单元格，见 ReadSheet
+	rows [][]string
 
 	serverIndex []int
 
 	// 默认值
 	defVal []string
 
+	// datetime/date 列
+	dateIndex map[int]bool
+
 	// key=match_text value=match_int
 	matchMap map[string]string
 
@@ -317,17 +338,17 @@ var listSheetNotFound = errors.New("没找到list页签")
 
 func parseStarXlsx(path string, data []byte) (*starfile, error) {
 
-	file, err := xlsx.OpenBinary(data)
+	file, err := OpenWorkbook(path, data)
 	if err != nil {
 		return nil, errors.Wrapf(err, "parse xlsx file %s", path)
 	}
 
 	// 找到需要处理sheet（读取sheet(list)）
-	sheet := file.Sheet["list"]
+	sheet := file.Sheet("list")
 	if sheet == nil {
-		for _, s := range file.Sheets {
-			if strings.ToLower(s.Name) == "list" {
-				sheet = s
+		for _, name := range file.SheetNames() {
+			if strings.ToLower(name) == "list" {
+				sheet = file.Sheet(name)
 				break
 			}
 		}
@@ -336,13 +357,18 @@ func parseStarXlsx(path string, data []byte) (*starfile, error) {
 		}
 	}
 
+	listRows, err := sheet.Rows()
+	if err != nil {
+		return nil, errors.Wrapf(err, "读取list页签失败 in %s", path)
+	}
+
 	var sheetNames []string
-	for _, row := range sheet.Rows {
-		if len(row.Cells) <= 0 {
+	for _, row := range listRows {
+		if len(row) <= 0 {
 			continue
 		}
 
-		name := strings.TrimSpace(row.Cells[0].String())
+		name := strings.TrimSpace(row[0])
 		if name != "" {
 			sheetNames = append(sheetNames, name)
 		}
@@ -355,48 +381,49 @@ func parseStarXlsx(path string, data []byte) (*starfile, error) {
 	// 检查sheet是否都存在
 	var sheets []*starsheet
 	for _, name := range sheetNames {
-		sheet := file.Sheet[name]
+		sheet := file.Sheet(name)
 		if sheet == nil {
 			return nil, errors.Errorf("sheet配置在list中，但是这个sheet不存在 %s in %s", name, path)
 		}
 
-		if len(sheet.Rows) < 5 {
-			return nil, errors.Errorf("sheet行数太少（%v） %s in %s", len(sheet.Rows), name, path)
+		rows, err := sheet.Rows()
+		if err != nil {
+			return nil, errors.Wrapf(err, "读取cell失败")
 		}
 
-		exportRow := sheet.Rows[3]
-		defRow := sheet.Rows[4]
+		if len(rows) < 5 {
+			return nil, errors.Errorf("sheet行数太少（%v） %s in %s", len(rows), name, path)
+		}
+
+		exportRow := rows[3]
+		defRow := rows[4]
 		var serverIndex []int
 		var defVal []string
-		for i, cell := range exportRow.Cells {
-			val, err := getCellString(cell)
-			if err != nil {
-				return nil, errors.Wrapf(err, "读取cell失败")
-			}
-
+		dateIndex := map[int]bool{}
+		for i, val := range exportRow {
 			val = strings.ToLower(strings.TrimSpace(val))
 			if val == "c" || val == "s" || val == "cs" {
 				serverIndex = append(serverIndex, i)
 
 				dd := ""
-				if i < len(defRow.Cells) {
-					def, err := getCellString(defRow.Cells[i])
-					if err != nil {
-						return nil, errors.Wrapf(err, "读取cell失败")
-					}
-					dd = strings.TrimSpace(def)
+				if i < len(defRow) {
+					dd = strings.TrimSpace(defRow[i])
 				}
 
 				defVal = append(defVal, dd)
+
+				if i < len(rows[2]) && isDateType(rows[2][i]) {
+					dateIndex[i] = true
+				}
 			}
 		}
 
 		starSheet := &starsheet{
 			sheet:       sheet,
+			rows:        rows,
 			serverIndex: serverIndex,
 			defVal:      defVal,
-			matchMap:    make(map[string]string),
-			filterMap:   make(map[int][]string),
+			dateIndex:   dateIndex,
 		}
 
 		sheets = append(sheets, starSheet)
@@ -404,143 +431,240 @@ func parseStarXlsx(path string, data []byte) (*starfile, error) {
 
 	f := &starfile{}
 	f.name = filepath.Base(path)
-	f.file = file
 	f.sheets = sheets
-
-	//name := strings.TrimSuffix(f.name, ".xlsx")
+	f.date1904 = file.Date1904()
 
 	for _, starSheet := range sheets {
-		sheet := starSheet.sheet
-		//nameRow := sheet.Rows[0]
-		typeRow := sheet.Rows[2]
+		matchMap, filterMap, err := ParseMatchSheet(starSheet.sheet.Name(), starSheet.rows)
+		if err != nil {
+			return nil, err
+		}
+
+		starSheet.matchMap = matchMap
+		starSheet.filterMap = filterMap
+	}
 
-		// 找到match_int 和 match_string|match_text 字段
+	return f, nil
+}
 
-		matchIntIndex := -1
-		matchTextIndex := -1
+// MatchTables key=MatchKey value=(key=match_text value=match_int)
+type MatchTables map[string]map[string]string
 
-		r := 3
-		for i, cell := range typeRow.Cells {
-			c := i + 1
+// MatchKey filter_string(xxx) 中引用的match表名：相对配置目录的路径（不带后缀）+页签名，小写
+// 例如 Buff.xlsx 的 Type 页签 -> bufftype，hero/Buff.xlsx -> hero/bufftype，不同目录下的同名表格不会冲突
+func MatchKey(fileName string, sheetName string) string {
+	name := filepath.ToSlash(fileName)
+	return strings.ToLower(strings.TrimSuffix(name, path.Ext(name)) + sheetName)
+}
 
-			text, err := getCellString(cell)
-			if err != nil {
-				return nil, errors.Wrapf(err, "%s 解析失败 %d行-%d列 %v", sheet.Name, r, c, cell)
+// ParseMatchSheet 找到页签中的 match_int/match_text 映射和 filter_string/filter_int 列
+func ParseMatchSheet(sheetName string, rows [][]string) (map[string]string, map[int][]string, error) {
+	matchMap := make(map[string]string)
+	filterMap := make(map[int][]string)
+
+	if len(rows) < 3 {
+		return matchMap, filterMap, nil
+	}
+
+	typeRow := rows[2]
+
+	// 找到match_int 和 match_string|match_text 字段
+
+	matchIntIndex := -1
+	matchTextIndex := -1
+
+	for i, text := range typeRow {
+		// 去掉校验规则，例如 match_int,>0
+		text, _ = SplitTypeRules(text)
+
+		if text == "match_int" {
+			if matchIntIndex != -1 {
+				return nil, nil, errors.Errorf("%s 解析失败 发现有多个match_int字段, %v, %v", sheetName, matchIntIndex+1, i+1)
+			}
+			matchIntIndex = i
+		} else if text == "match_text" {
+			if matchTextIndex != -1 {
+				return nil, nil, errors.Errorf("%s 解析失败 发现有多个match_text字段, %v, %v", sheetName, matchTextIndex+1, i+1)
 			}
+			matchTextIndex = i
+		} else if names := FilterMatchNames(text); len(names) > 0 {
+			filterMap[i] = names
+		}
+	}
 
-			if text == "match_int" {
-				if matchIntIndex != -1 {
-					return nil, errors.Errorf("%s 解析失败 发现有多个match_int字段, %v, %v", sheet.Name, matchIntIndex+1, i+1)
-				}
-				matchIntIndex = i
-			} else if text == "match_text" {
-				if matchTextIndex != -1 {
-					return nil, errors.Errorf("%s 解析失败 发现有多个match_text字段, %v, %v", sheet.Name, matchTextIndex+1, i+1)
-				}
-				matchTextIndex = i
-			} else {
-				str := strings.TrimPrefix(text, "filter_string(")
-				if str == text {
-					str = strings.TrimPrefix(text, "filter_int(")
-				}
+	if matchIntIndex != -1 {
+		if matchTextIndex != -1 {
+			// 遍历获取到所有的映射关系（从第六行开始遍历）
+			for i := 5; i < len(rows); i++ {
+				row := rows[i]
 
-				if str != text {
-					str = strings.TrimSuffix(str, ")")
+				if matchIntIndex < len(row) && matchTextIndex < len(row) {
+					intValue := strings.TrimSpace(row[matchIntIndex])
+					textValue := strings.TrimSpace(row[matchTextIndex])
 
-					arr := strings.Split(str, ",")
-					for _, v := range arr {
-						starSheet.filterMap[i] = append(starSheet.filterMap[i], strings.ToLower(strings.TrimSpace(v)))
+					if intValue == "" {
+						if textValue != "" {
+							return nil, nil, errors.Errorf("%s 解析失败(match_int empty) %d行-%d列 %v", sheetName, i+1, matchIntIndex+1, textValue)
+						}
+						// 都是空，跳过
+						continue
+					} else if textValue == "" {
+						return nil, nil, errors.Errorf("%s 解析失败(match_text empty) %d行-%d列 %v", sheetName, i+1, matchTextIndex+1, intValue)
 					}
+
+					if _, exist := matchMap[textValue]; exist {
+						return nil, nil, errors.Errorf("%s 解析 %d行-%d列 %v, 发现重复的match_text", sheetName, i+1, matchTextIndex+1, textValue)
+					}
+
+					matchMap[textValue] = intValue
 				}
+
 			}
+
+		} else {
+			return nil, nil, errors.Errorf("%s 解析失败 存在match_int但是不存在match_text字段, %v, %v", sheetName, matchIntIndex+1, matchTextIndex+1)
 		}
 
-		if matchIntIndex != -1 {
-			if matchTextIndex != -1 {
-				// 遍历获取到所有的映射关系（从第六行开始遍历）
-				for i := 5; i < len(sheet.Rows); i++ {
-					row := sheet.Rows[i]
-
-					if matchIntIndex < len(row.Cells) && matchTextIndex < len(row.Cells) {
-						intCell := row.Cells[matchIntIndex]
-						intValue, err := getCellString(intCell)
-						if err != nil {
-							return nil, errors.Wrapf(err, "%s 解析失败 %d行-%d列 %v", sheet.Name, i+1, matchIntIndex+1, intCell)
-						}
+	} else if matchTextIndex != -1 {
+		return nil, nil, errors.Errorf("%s 解析失败 不存在match_int但是存在match_text字段, %v, %v", sheetName, matchIntIndex+1, matchTextIndex+1)
+	}
 
-						textCell := row.Cells[matchTextIndex]
-						textValue, err := getCellString(textCell)
-						if err != nil {
-							return nil, errors.Wrapf(err, "%s 解析失败 %d行-%d列 %v", sheet.Name, i+1, matchTextIndex+1, textCell)
-						}
+	return matchMap, filterMap, nil
+}
 
-						intValue = strings.TrimSpace(intValue)
-						textValue = strings.TrimSpace(textValue)
+// MatchSheet 配置了 match_int/match_text 的页签
+type MatchSheet struct {
+	// 相对配置目录的路径，带后缀
+	FileName string
 
-						if intValue == "" {
-							if textValue != "" {
-								return nil, errors.Wrapf(err, "%s 解析失败(match_int empty) %d行-%d列 %v", sheet.Name, i+1, matchTextIndex+1, textCell)
-							}
-							// 都是空，跳过
-							continue
-						} else if textValue == "" {
-							return nil, errors.Wrapf(err, "%s 解析失败(match_text empty) %d行-%d列 %v", sheet.Name, i+1, matchTextIndex+1, textCell)
-						}
+	SheetName string
 
-						if _, exist := starSheet.matchMap[textValue]; exist {
-							return nil, errors.Errorf("%s 解析 %d行-%d列 %v, 发现重复的match_text", sheet.Name, i+1, matchTextIndex+1, textCell)
-						}
+	// key=match_text value=match_int
+	MatchMap map[string]string
+}
 
-						starSheet.matchMap[textValue] = intValue
-					}
+func NewMatchTables(sheets []*MatchSheet) MatchTables {
+	matchTables := make(MatchTables)
+	for _, sheet := range sheets {
+		matchTables[MatchKey(sheet.FileName, sheet.SheetName)] = sheet.MatchMap
+	}
 
-				}
+	return matchTables
+}
+
+// LoadXlsxMatchSheets 读取目录（包括子目录）下所有表格中的match页签，filter_string 可以引用其他表格的match表
+// FileName 是相对dir的路径，match表名带上目录，见 MatchKey
+func LoadXlsxMatchSheets(dir string) ([]*MatchSheet, error) {
+	return NewTableCache(dir).MatchSheets()
+}
+
+// MatchSheets 和 LoadXlsxMatchSheets 一样，读取的表格后面生成数据库时不需要重新解析
+func (c *TableCache) MatchSheets() ([]*MatchSheet, error) {
+	fss, err := WalkDir(c.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "读取目录失败 %s", c.dir)
+	}
+
+	var matchSheets []*MatchSheet
+
+	for _, fName := range fss {
+
+		if !IsWorkbook(fName) {
+			continue
+		}
 
-			} else {
-				return nil, errors.Errorf("%s 解析失败 存在match_int但是不存在match_text字段, %v, %v", sheet.Name, matchIntIndex+1, matchTextIndex+1)
+		file, err := c.Workbook(fName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse xlsx file %s", fName)
+		}
+
+		listSheet := file.Sheet("list")
+		if listSheet == nil {
+			continue
+		}
+
+		listRows, err := listSheet.Rows()
+		if err != nil {
+			return nil, errors.Wrapf(err, "读取list页签失败 in %s", fName)
+		}
+
+		for _, row := range listRows {
+			if len(row) < 1 {
+				continue
+			}
+
+			sheet := file.Sheet(strings.TrimSpace(row[0]))
+			if sheet == nil {
+				continue
+			}
+
+			rows, err := sheet.Rows()
+			if err != nil {
+				return nil, errors.Wrapf(err, "读取cell失败 in %s", fName)
+			}
+
+			if !hasMatchColumn(rows) {
+				continue
+			}
+
+			matchMap, _, err := ParseMatchSheet(sheet.Name(), rows)
+			if err != nil {
+				return nil, errors.Wrapf(err, "in %s", fName)
 			}
 
-		} else if matchTextIndex != -1 {
-			return nil, errors.Errorf("%s 解析失败 不存在match_int但是存在match_text字段, %v, %v", sheet.Name, matchIntIndex+1, matchTextIndex+1)
+			if len(matchMap) > 0 {
+				matchSheets = append(matchSheets, &MatchSheet{
+					FileName:  fName,
+					SheetName: sheet.Name(),
+					MatchMap:  matchMap,
+				})
+			}
 		}
 	}
 
-	return f, nil
+	return matchSheets, nil
 }
 
-func getCellString(cell *xlsx.Cell) (string, error) {
-	text := cell.Value
-
-	var err error
-	if text != "" {
-		switch cell.Type() {
-		case xlsx.CellTypeString:
-			text = cell.String()
-		case xlsx.CellTypeStringFormula:
-			text, err = cell.FormattedValue()
-		case xlsx.CellTypeNumeric:
-			var result float64
-			result, err = cell.Float()
-			if result == float64(int64(result)) {
-				text = fmt.Sprintf("%d", int64(result))
-			} else {
-				text = strconv.FormatFloat(result, 'f', -1, 64)
-			}
+// hasMatchColumn 类型行中有match_int或者match_text
+func hasMatchColumn(rows [][]string) bool {
+	if len(rows) < 3 {
+		return false
+	}
+
+	for _, text := range rows[2] {
+		text, _ = SplitTypeRules(text)
+		if text == "match_int" || text == "match_text" {
+			return true
 		}
 	}
 
-	if err == nil {
-		// 用2个空格替换1个tab
-		text = strings.ReplaceAll(text, "\t", "  ")
+	return false
+}
+
+// FilterMatchNames filter_string(a,b) filter_int(a,b) 引用的match表名，不是filter类型返回nil
+func FilterMatchNames(typeText string) []string {
+	str := strings.TrimPrefix(typeText, "filter_string(")
+	if str == typeText {
+		str = strings.TrimPrefix(typeText, "filter_int(")
+	}
+
+	if str == typeText {
+		return nil
+	}
+
+	str = strings.TrimSuffix(str, ")")
 
-		// 去掉双引号
-		text = strings.ReplaceAll(text, "\"", "")
+	var names []string
+	arr := strings.Split(str, ",")
+	for _, v := range arr {
+		names = append(names, strings.ToLower(strings.TrimSpace(v)))
 	}
 
-	return text, err
+	return names
 }
 
-func replaceMatchString(s string, matchDataMap map[string]map[string]string, matchNames []string) (string, error) {
+// ReplaceMatchString 把 {match_text} 替换成对应的match_int，例如 {haha}_1_{hehe}_2
+func ReplaceMatchString(s string, matchDataMap MatchTables, matchNames []string) (string, error) {
 	// {haha}_1_{hehe}_2
 
 	array := strings.Split(s, "{")
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestMatchKey(t *testing.T) {
+	tests := []struct {
+		file  string
+		sheet string
+		want  string
+	}{
+		{file: "Buff.xlsx", sheet: "Type", want: "bufftype"},
+		{file: "hero/Buff.xlsx", sheet: "Type", want: "hero/bufftype"},
+		{file: "hero/sub/Buff.ods", sheet: "Type", want: "hero/sub/bufftype"},
+	}
+
+	for _, tt := range tests {
+		if got := MatchKey(tt.file, tt.sheet); got != tt.want {
+			t.Errorf("MatchKey(%q, %q) = %q, want %q", tt.file, tt.sheet, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/axgle/mahonia"
+	"github.com/pkg/errors"
+	unicode16 "golang.org/x/text/encoding/unicode"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// TextSheetName csv、tsv、json 文本表没有list页签，一个文件就是一个页签，使用这个页签名
+var TextSheetName = "Data"
+
+var textTableExts = []string{".csv", ".tsv", ".json"}
+
+// IsTableFile 是否是转表支持的表格（WorkbookReaders中的表格和文本表），Excel打开表格时生成的锁文件（~$开头）不算
+func IsTableFile(fName string) bool {
+	if strings.HasPrefix(filepath.Base(fName), "~$") {
+		return false
+	}
+
+	return IsWorkbook(fName) || IsTextTable(fName)
+}
+
+// IsTextTable csv、tsv、json 文本表
+func IsTextTable(fName string) bool {
+	return hasExt(fName, textTableExts)
+}
+
+func hasExt(fName string, exts []string) bool {
+	ext := strings.ToLower(filepath.Ext(fName))
+	for _, v := range exts {
+		if ext == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Table 转表读取的表格文件，表格按list页签读取，文本表只有一个页签 TextSheetName
+type Table struct {
+	// list页签的内容
+	ListRows [][]string
+
+	Workbook Workbook
+}
+
+// OpenTable 按后缀读取表格，表格没有list页签时返回错误
+func OpenTable(fName string, data []byte) (*Table, error) {
+	workbook, err := openTableWorkbook(fName, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTable(workbook)
+}
+
+// openTableWorkbook 文本表也按只有一个页签的表格读取
+func openTableWorkbook(fName string, data []byte) (Workbook, error) {
+	if IsTextTable(fName) {
+		rows, err := ReadTextTable(fName, data)
+		if err != nil {
+			return nil, err
+		}
+
+		return &textWorkbook{sheet: &textSheet{rows: rows}}, nil
+	}
+
+	return OpenWorkbook(fName, data)
+}
+
+func newTable(workbook Workbook) (*Table, error) {
+	if _, isText := workbook.(*textWorkbook); isText {
+		return &Table{ListRows: [][]string{{TextSheetName}}, Workbook: workbook}, nil
+	}
+
+	listSheet := workbook.Sheet("list")
+	if listSheet == nil {
+		return nil, errors.Errorf("表格数据中没有找到list页签")
+	}
+
+	listRows, err := listSheet.Rows()
+	if err != nil {
+		return nil, errors.Wrapf(err, "读取list页签失败")
+	}
+
+	return &Table{ListRows: listRows, Workbook: workbook}, nil
+}
+
+// Sheet 页签不存在返回nil
+func (t *Table) Sheet(name string) WorkbookSheet {
+	return t.Workbook.Sheet(strings.TrimSpace(name))
+}
+
+// Date1904 Excel日期序列值使用1904日期系统
+func (t *Table) Date1904() bool {
+	return t.Workbook.Date1904()
+}
+
+// textWorkbook 文本表只有一个页签
+type textWorkbook struct {
+	sheet *textSheet
+}
+
+func (w *textWorkbook) SheetNames() []string {
+	return []string{TextSheetName}
+}
+
+func (w *textWorkbook) Sheet(name string) WorkbookSheet {
+	if name != TextSheetName {
+		return nil
+	}
+
+	return w.sheet
+}
+
+func (w *textWorkbook) Date1904() bool {
+	return false
+}
+
+type textSheet struct {
+	rows [][]string
+}
+
+func (s *textSheet) Name() string {
+	return TextSheetName
+}
+
+func (s *textSheet) Rows() ([][]string, error) {
+	return s.rows, nil
+}
+
+func (s *textSheet) CellDuration(row, col int) (time.Duration, bool) {
+	return 0, false
+}
+
+// ReadTextTable 读取csv、tsv、json文本表，表头和xlsx页签一样：描述、标题、类型、导出标记、默认值，后面是数据
+// 支持utf8（带不带BOM都可以）、utf16（带BOM）、gbk编码
+// json是二维数组时每个元素是一行；是对象数组时和服务器一样展开嵌套对象，列类型按值推断
+func ReadTextTable(fName string, data []byte) ([][]string, error) {
+	text, err := DecodeText(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "文件编码转换失败 %s", fName)
+	}
+
+	var rows [][]string
+
+	switch strings.ToLower(filepath.Ext(fName)) {
+	case ".csv":
+		r := csv.NewReader(strings.NewReader(text))
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+
+		rows, err = r.ReadAll()
+		if err != nil {
+			return nil, errors.Wrapf(err, "解析csv失败 %s", fName)
+		}
+	case ".tsv":
+		lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+		if len(lines) > 0 && lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+
+		for _, line := range lines {
+			rows = append(rows, strings.Split(line, "\t"))
+		}
+	case ".json":
+		rows, err = readJsonRows(fName, text)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("不支持的文本表格式 %s", fName)
+	}
+
+	// 和xlsx单元格的读取方式保持一致
+	for _, row := range rows {
+		for j := range row {
+			row[j] = DefaultCellOption.normalize(row[j])
+		}
+	}
+
+	return rows, nil
+}
+
+// readJsonRows 单元格是数组时用逗号连接，对应 xxx_list 类型
+func readJsonRows(fName string, text string) ([][]string, error) {
+	if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, "[") && strings.HasPrefix(strings.TrimSpace(trimmed[1:]), "{") {
+		t, err := flattenJson(fName, []byte(text))
+		if err != nil {
+			return nil, err
+		}
+
+		return t.tableRows(), nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	var root []interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return nil, errors.Wrapf(err, "%s 文件格式不对，转表的json最外层必须是数组", fName)
+	}
+
+	rows := make([][]string, 0, len(root))
+
+	for i, v := range root {
+		values, ok := v.([]interface{})
+		if !ok {
+			return nil, errors.Errorf("%s 文件格式不对，第%v行必须是数组（表头和xlsx一样：描述、标题、类型、导出标记、默认值），或者每行都是对象", fName, i+1)
+		}
+
+		row := make([]string, len(values))
+		for j, value := range values {
+			cell, err := jsonCellString(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s 第%v行第%v列", fName, i+1, j+1)
+			}
+			row[j] = cell
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func jsonCellString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if _, isArray := item.([]interface{}); isArray {
+				return "", errors.Errorf("数组元素不能是数组")
+			}
+
+			s, err := jsonCellString(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	}
+
+	return "", errors.Errorf("单元格不能是Object（不能用嵌套格式）")
+}
+
+// DecodeText 文本文件转换成utf8，和服务器读取tsv一样支持utf16（带BOM）和gbk
+func DecodeText(data []byte) (string, error) {
+	switch {
+	case len(data) >= 2 && data[0] == 0xfe && data[1] == 0xff:
+		decoded, err := unicode16.UTF16(unicode16.BigEndian, unicode16.UseBOM).NewDecoder().Bytes(data)
+		return string(decoded), err
+	case len(data) >= 2 && data[0] == 0xff && data[1] == 0xfe:
+		decoded, err := unicode16.UTF16(unicode16.LittleEndian, unicode16.UseBOM).NewDecoder().Bytes(data)
+		return string(decoded), err
+	}
+
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+
+	return mahonia.NewDecoder("gbk").ConvertString(string(data)), nil
+}
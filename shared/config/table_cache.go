@@ -0,0 +1,101 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+	"sync"
+)
+
+// TableCache 一次转表中每个表格只读取和解析一次，生成proto、读取match表、校验和生成数据库共用，并发安全
+// fName 是相对配置目录的路径，和 WalkDir 返回的一致
+type TableCache struct {
+	dir string
+
+	mux     sync.Mutex
+	entries map[string]*tableEntry
+}
+
+type tableEntry struct {
+	readOnce sync.Once
+	data     []byte
+	errRead  error
+
+	openOnce sync.Once
+	workbook Workbook
+	errOpen  error
+}
+
+func NewTableCache(dir string) *TableCache {
+	if !strings.HasSuffix(dir, "/") {
+		dir = dir + "/"
+	}
+
+	return &TableCache{dir: dir, entries: map[string]*tableEntry{}}
+}
+
+// Dir 配置目录，带 /
+func (c *TableCache) Dir() string {
+	return c.dir
+}
+
+func (c *TableCache) entry(fName string) *tableEntry {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	e := c.entries[fName]
+	if e == nil {
+		e = &tableEntry{}
+		c.entries[fName] = e
+	}
+
+	return e
+}
+
+// Data 文件内容，计算md5等不需要解析表格时使用
+func (c *TableCache) Data(fName string) ([]byte, error) {
+	e := c.entry(fName)
+
+	e.readOnce.Do(func() {
+		e.data, e.errRead = os.ReadFile(c.dir + fName)
+		if e.errRead != nil {
+			e.errRead = errors.Errorf("读取文件失败,path:%v %v", c.dir+fName, e.errRead)
+		}
+	})
+
+	return e.data, e.errRead
+}
+
+// Workbook 解析后的表格，文本表只有一个页签 TextSheetName
+func (c *TableCache) Workbook(fName string) (Workbook, error) {
+	data, err := c.Data(fName)
+	if err != nil {
+		return nil, err
+	}
+
+	e := c.entry(fName)
+
+	e.openOnce.Do(func() {
+		e.workbook, e.errOpen = openTableWorkbook(fName, data)
+	})
+
+	return e.workbook, e.errOpen
+}
+
+// Table 和 OpenTable 一样，表格没有list页签时返回错误
+func (c *TableCache) Table(fName string) (*Table, error) {
+	workbook, err := c.Workbook(fName)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTable(workbook)
+}
+
+// Release 表格不再使用时释放内存，之后再访问会重新读取
+func (c *TableCache) Release(fName string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	delete(c.entries, fName)
+}
@@ -251,6 +251,58 @@ func ParseValidator(str, sep string, array bool, wlist []string, defaults []stri
 	return out
 }
 
+// SplitTypeRules 表格类型行 int,>0,notnil 拆成类型 int 和校验规则 >0,notnil，括号中的逗号不拆分
+func SplitTypeRules(text string) (string, string) {
+	depth := 0
+	for i, r := range text {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+			}
+		}
+	}
+
+	return strings.TrimSpace(text), ""
+}
+
+// ParseColumnValidator 表格类型行中的校验规则，第一项是选项（notnil count=3 等）时使用列类型对应的 base 校验
+func ParseColumnValidator(rules, base, sep string) *Validator {
+	parts := strings.Split(rules, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	if isValidatorOption(strings.ToLower(parts[0])) {
+		parts = append([]string{base}, parts...)
+	}
+
+	// 单独的 >0 >=0 按列类型比较，float/fixed 列不能使用整数校验
+	if first := strings.ToLower(parts[0]); first == ">0" || first == ">=0" {
+		if _, exist := validatorMap[base+first]; exist {
+			parts[0] = base + first
+		}
+	}
+
+	return ParseValidator(strings.Join(parts, ","), sep, false, nil, nil, true)
+}
+
+func isValidatorOption(opt string) bool {
+	switch opt {
+	case "d", "dup", "duplicate",
+		"notnil", "not nil", "notnull", "not null",
+		"notallnil", "not all nil", "notallnull", "not all null",
+		"allnilornot", "case":
+		return true
+	}
+
+	return strings.HasPrefix(opt, "tips") || strings.HasPrefix(opt, "count") || strings.HasPrefix(opt, "sum")
+}
+
 func parseParts(t *Validator, parts ...string) *Validator {
 	for _, opt := range parts {
 		switch opt {
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tealeg/xlsx"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Workbook 表格文件，不同格式使用不同的实现，单元格统一读取成字符串，见 ReadSheet
+type Workbook interface {
+	// SheetNames 所有页签名，按表格中的顺序
+	SheetNames() []string
+
+	// Sheet 页签不存在返回nil
+	Sheet(name string) WorkbookSheet
+
+	// Date1904 Excel日期序列值使用1904日期系统
+	Date1904() bool
+}
+
+type WorkbookSheet interface {
+	Name() string
+
+	// Rows 所有单元格，按 DefaultCellOption 读取：默认合并单元格只有左上角有值，开启 FillMerged（-fillMerged）时填充到整个合并区域
+	Rows() ([][]string, error)
+
+	// CellDuration 时间格式的单元格（例如 1:30:00）对应的时长，不是时间格式返回false
+	CellDuration(row, col int) (time.Duration, bool)
+}
+
+// WorkbookReader 读取一种格式的表格
+type WorkbookReader func(data []byte) (Workbook, error)
+
+// WorkbookReaders key=后缀（小写），xlsm和xlsx格式相同，只是多了宏
+var WorkbookReaders = map[string]WorkbookReader{
+	".xlsx": openXlsxWorkbook,
+	".xlsm": openXlsxWorkbook,
+	".ods":  openOdsWorkbook,
+}
+
+// IsWorkbook 是否是支持的表格格式，Excel打开表格时生成的锁文件（~$开头）不算
+func IsWorkbook(fName string) bool {
+	if strings.HasPrefix(filepath.Base(fName), "~$") {
+		return false
+	}
+
+	_, exist := WorkbookReaders[strings.ToLower(filepath.Ext(fName))]
+	return exist
+}
+
+// OpenWorkbook 按后缀读取表格
+func OpenWorkbook(fName string, data []byte) (Workbook, error) {
+	reader, exist := WorkbookReaders[strings.ToLower(filepath.Ext(fName))]
+	if !exist {
+		return nil, errors.Errorf("不支持的表格格式 %s", fName)
+	}
+
+	return reader(data)
+}
+
+type xlsxWorkbook struct {
+	file *xlsx.File
+
+	// 同一个页签只读取一次单元格
+	sheets map[string]*xlsxSheet
+}
+
+func openXlsxWorkbook(data []byte) (Workbook, error) {
+	file, err := xlsx.OpenBinary(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "解析表格数据失败 OpenBinary")
+	}
+
+	w := &xlsxWorkbook{file: file, sheets: map[string]*xlsxSheet{}}
+	for name, sheet := range file.Sheet {
+		w.sheets[name] = &xlsxSheet{sheet: sheet}
+	}
+
+	return w, nil
+}
+
+func (w *xlsxWorkbook) SheetNames() []string {
+	names := make([]string, 0, len(w.file.Sheets))
+	for _, sheet := range w.file.Sheets {
+		names = append(names, sheet.Name)
+	}
+
+	return names
+}
+
+func (w *xlsxWorkbook) Sheet(name string) WorkbookSheet {
+	sheet := w.sheets[name]
+	if sheet == nil {
+		return nil
+	}
+
+	return sheet
+}
+
+func (w *xlsxWorkbook) Date1904() bool {
+	return w.file.Date1904
+}
+
+type xlsxSheet struct {
+	sheet *xlsx.Sheet
+
+	once    sync.Once
+	rows    [][]string
+	errRows error
+}
+
+func (s *xlsxSheet) Name() string {
+	return s.sheet.Name
+}
+
+func (s *xlsxSheet) Rows() ([][]string, error) {
+	s.once.Do(func() {
+		s.rows, s.errRows = ReadSheet(s.sheet, DefaultCellOption)
+	})
+
+	return s.rows, s.errRows
+}
+
+// CellDuration 时间格式的单元格存的是天数的小数
+func (s *xlsxSheet) CellDuration(row, col int) (time.Duration, bool) {
+	if row >= len(s.sheet.Rows) || s.sheet.Rows[row] == nil || col >= len(s.sheet.Rows[row].Cells) {
+		return 0, false
+	}
+
+	cell := s.sheet.Rows[row].Cells[col]
+	if cell.Type() != xlsx.CellTypeNumeric || !cell.IsTime() {
+		return 0, false
+	}
+
+	fraction, err := cell.Float()
+	if err != nil {
+		return 0, false
+	}
+
+	return ExcelFractionToDuration(fraction), true
+}